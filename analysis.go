@@ -0,0 +1,346 @@
+package gdpgen
+
+import (
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// grammarIndex numbers the symbols and products of an augmented grammar so
+// that table construction can work on integers and bitsets. Terminals get
+// the ids [0, nTerms), eot being 0; non-terminals follow.
+type grammarIndex struct {
+	g        *G
+	start    *ProductElem
+	symbols  []*ProductElem
+	ids      map[*ProductElem]int
+	nTerms   int
+	products []*Product
+	prodIDs  map[*Product]int
+	heads    []int
+	bodies   [][]int // body symbol ids, EmptyElem dropped
+	prodsOf  [][]int // product ids by head symbol id
+
+	nullable []bool
+	first    []bitset // FIRST of every symbol, over terminal ids
+
+	// FIRST and nullability of the body suffix after each dot position
+	firstAfter    [][]bitset
+	nullableAfter [][]bool
+
+	follow []bitset // FOLLOW of every symbol, over terminal ids
+}
+
+func newGrammarIndex(g *G) *grammarIndex {
+	idx := &grammarIndex{
+		g:       g,
+		start:   g.StartSymbol,
+		ids:     make(map[*ProductElem]int),
+		prodIDs: make(map[*Product]int),
+	}
+	addSymbol := func(sym *ProductElem) {
+		if _, ok := idx.ids[sym]; !ok && sym != EmptyElem {
+			idx.ids[sym] = len(idx.symbols)
+			idx.symbols = append(idx.symbols, sym)
+		}
+	}
+	symbols := g.GetSymbolSet()
+	addSymbol(eot)
+	for _, sym := range symbols {
+		if sym.IsTerminal {
+			addSymbol(sym)
+		}
+	}
+	idx.nTerms = len(idx.symbols)
+	for _, sym := range symbols {
+		if !sym.IsTerminal {
+			addSymbol(sym)
+		}
+	}
+
+	idx.prodsOf = make([][]int, len(idx.symbols))
+	for i, p := range g.Products {
+		idx.products = append(idx.products, p)
+		idx.prodIDs[p] = i
+		head := idx.ids[p.Head]
+		body := []int{}
+		for _, elem := range p.Body {
+			if elem != EmptyElem {
+				body = append(body, idx.ids[elem])
+			}
+		}
+		idx.heads = append(idx.heads, head)
+		idx.bodies = append(idx.bodies, body)
+		idx.prodsOf[head] = append(idx.prodsOf[head], i)
+	}
+
+	idx.computeNullable()
+	idx.computeFirst()
+	idx.computeSuffixes()
+	idx.computeFollow()
+	return idx
+}
+
+// analysis returns the index of g, computing it again only if products were
+// added or the start symbol changed since the last call. AddProduct drops
+// the index; the other checks catch edits of g.Products and g.StartSymbol.
+func (g *G) analysis() *grammarIndex {
+	if g.index == nil || len(g.index.products) != len(g.Products) || g.index.start != g.StartSymbol {
+		g.index = newGrammarIndex(g)
+	}
+	return g.index
+}
+
+// Nullable reports whether sym derives the empty string.
+func (g *G) Nullable(sym *ProductElem) bool {
+	if sym == EmptyElem {
+		return true
+	}
+	idx := g.analysis()
+	id, ok := idx.ids[sym]
+	return ok && idx.nullable[id]
+}
+
+// First returns the terminals that can begin a string derived from sym, in
+// the order they first appear in the products. Whether sym also derives
+// the empty string is told by Nullable.
+func (g *G) First(sym *ProductElem) []*ProductElem {
+	idx := g.analysis()
+	id, ok := idx.ids[sym]
+	if !ok {
+		return []*ProductElem{}
+	}
+	return idx.terminals(idx.first[id])
+}
+
+// FirstOfSequence returns the terminals that can begin a string derived
+// from seq, and whether seq derives the empty string.
+func (g *G) FirstOfSequence(seq []*ProductElem) ([]*ProductElem, bool) {
+	return g.analysis().firstOfSequence(seq)
+}
+
+func (idx *grammarIndex) firstOfSequence(seq []*ProductElem) ([]*ProductElem, bool) {
+	first := newBitset(idx.nTerms)
+	for _, sym := range seq {
+		if sym == EmptyElem {
+			continue
+		}
+		id, ok := idx.ids[sym]
+		if !ok {
+			if sym.IsTerminal {
+				return append(idx.terminals(first), sym), false
+			}
+			return idx.terminals(first), false
+		}
+		first.union(idx.first[id])
+		if !idx.nullable[id] {
+			return idx.terminals(first), false
+		}
+	}
+	return idx.terminals(first), true
+}
+
+// Follow returns the terminals that can come right after sym in a sentential
+// form. The end of input is the terminal with Sig "$".
+func (g *G) Follow(sym *ProductElem) []*ProductElem {
+	idx := g.analysis()
+	id, ok := idx.ids[sym]
+	if !ok {
+		return []*ProductElem{}
+	}
+	return idx.terminals(idx.follow[id])
+}
+
+func (idx *grammarIndex) terminals(set bitset) []*ProductElem {
+	terms := []*ProductElem{}
+	for _, id := range set.members() {
+		terms = append(terms, idx.symbols[id])
+	}
+	return terms
+}
+
+func (idx *grammarIndex) isTerminal(sym int) bool {
+	return sym < idx.nTerms
+}
+
+// computeNullable iterates to a fixed point: a head is nullable once one of
+// its bodies consists of nullable symbols only.
+func (idx *grammarIndex) computeNullable() {
+	idx.nullable = make([]bool, len(idx.symbols))
+	changed := true
+	for changed {
+		changed = false
+		for p, body := range idx.bodies {
+			if idx.nullable[idx.heads[p]] {
+				continue
+			}
+			all := true
+			for _, sym := range body {
+				if !idx.nullable[sym] {
+					all = false
+					break
+				}
+			}
+			if all {
+				idx.nullable[idx.heads[p]] = true
+				changed = true
+			}
+		}
+	}
+}
+
+// computeFirst iterates to a fixed point, so left recursion terminates.
+func (idx *grammarIndex) computeFirst() {
+	idx.first = make([]bitset, len(idx.symbols))
+	for sym := range idx.symbols {
+		idx.first[sym] = newBitset(idx.nTerms)
+		if idx.isTerminal(sym) {
+			idx.first[sym].add(sym)
+		}
+	}
+	changed := true
+	for changed {
+		changed = false
+		for p, body := range idx.bodies {
+			head := idx.first[idx.heads[p]]
+			for _, sym := range body {
+				if head.union(idx.first[sym]) {
+					changed = true
+				}
+				if !idx.nullable[sym] {
+					break
+				}
+			}
+		}
+	}
+}
+
+func (idx *grammarIndex) computeSuffixes() {
+	idx.firstAfter = make([][]bitset, len(idx.bodies))
+	idx.nullableAfter = make([][]bool, len(idx.bodies))
+	for p, body := range idx.bodies {
+		idx.firstAfter[p] = make([]bitset, len(body)+1)
+		idx.nullableAfter[p] = make([]bool, len(body)+1)
+		idx.firstAfter[p][len(body)] = newBitset(idx.nTerms)
+		idx.nullableAfter[p][len(body)] = true
+		for dot := len(body) - 1; dot >= 0; dot-- {
+			sym := body[dot]
+			set := idx.first[sym].clone()
+			if idx.nullable[sym] {
+				set.union(idx.firstAfter[p][dot+1])
+			}
+			idx.firstAfter[p][dot] = set
+			idx.nullableAfter[p][dot] = idx.nullable[sym] && idx.nullableAfter[p][dot+1]
+		}
+	}
+}
+
+// computeFollow iterates to a fixed point. The end of input follows the
+// start symbol.
+func (idx *grammarIndex) computeFollow() {
+	idx.follow = make([]bitset, len(idx.symbols))
+	for sym := range idx.symbols {
+		idx.follow[sym] = newBitset(idx.nTerms)
+	}
+	if start, ok := idx.ids[idx.start]; ok {
+		idx.follow[start].add(idx.ids[eot])
+	}
+	changed := true
+	for changed {
+		changed = false
+		for p, body := range idx.bodies {
+			for dot, sym := range body {
+				if idx.isTerminal(sym) {
+					continue
+				}
+				if idx.follow[sym].union(idx.firstAfter[p][dot+1]) {
+					changed = true
+				}
+				if idx.nullableAfter[p][dot+1] && idx.follow[sym].union(idx.follow[idx.heads[p]]) {
+					changed = true
+				}
+			}
+		}
+	}
+}
+
+// bitset is a fixed-size set of small non-negative integers.
+type bitset []uint64
+
+func newBitset(size int) bitset {
+	return make(bitset, (size+63)/64)
+}
+
+func (b bitset) add(i int) {
+	b[i/64] |= 1 << uint(i%64)
+}
+
+func (b bitset) has(i int) bool {
+	return b[i/64]&(1<<uint(i%64)) != 0
+}
+
+// union adds the members of o to b and reports whether b changed.
+func (b bitset) union(o bitset) bool {
+	changed := false
+	for i := range b {
+		if merged := b[i] | o[i]; merged != b[i] {
+			b[i] = merged
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (b bitset) intersects(o bitset) bool {
+	for i := range b {
+		if b[i]&o[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (b bitset) subsetOf(o bitset) bool {
+	for i := range b {
+		if b[i]&^o[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b bitset) clone() bitset {
+	c := make(bitset, len(b))
+	copy(c, b)
+	return c
+}
+
+func (b bitset) empty() bool {
+	for _, w := range b {
+		if w != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// members returns the elements of b in increasing order.
+func (b bitset) members() []int {
+	members := []int{}
+	for i, w := range b {
+		for w != 0 {
+			members = append(members, i*64+bits.TrailingZeros64(w))
+			w &= w - 1
+		}
+	}
+	return members
+}
+
+func (b bitset) key() string {
+	var sb strings.Builder
+	for _, w := range b {
+		sb.WriteString(strconv.FormatUint(w, 36))
+		sb.WriteByte('.')
+	}
+	return sb.String()
+}
@@ -0,0 +1,58 @@
+package gdpgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func sigs(elems []*ProductElem) string {
+	names := make([]string, len(elems))
+	for i, elem := range elems {
+		names[i] = elem.Sig
+	}
+	return strings.Join(names, " ")
+}
+
+func TestAnalysis(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(`
+%token id /[a-z]+/
+s : a "x" | "(" s ")" ;
+a : b id | %empty ;
+b : "y" | %empty ;
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sym      string
+		nullable bool
+		first    string
+		follow   string
+	}{
+		{"s", false, "x ( id y", "$ )"},
+		{"a", true, "id y", "x"},
+		{"b", true, "y", "id"},
+	}
+	for _, tc := range tests {
+		sym := g.NonTerminal(tc.sym)
+		if g.Nullable(sym) != tc.nullable {
+			t.Errorf("Nullable(%v) = %v", tc.sym, !tc.nullable)
+		}
+		if got := sigs(g.First(sym)); got != tc.first {
+			t.Errorf("First(%v) = %v, want %v", tc.sym, got, tc.first)
+		}
+		if got := sigs(g.Follow(sym)); got != tc.follow {
+			t.Errorf("Follow(%v) = %v, want %v", tc.sym, got, tc.follow)
+		}
+	}
+	first, nullable := g.FirstOfSequence([]*ProductElem{g.NonTerminal("b"), g.NonTerminal("a")})
+	if sigs(first) != "id y" || !nullable {
+		t.Errorf("FirstOfSequence(b a) = %v, %v", sigs(first), nullable)
+	}
+
+	// the cached analysis is dropped when a product is added
+	g.AddProduct(&Product{g.NonTerminal("b"), []*ProductElem{g.Terminal("z")}, nil})
+	if got := sigs(g.First(g.NonTerminal("b"))); got != "y z" {
+		t.Errorf("First(b) after AddProduct = %v, want y z", got)
+	}
+}
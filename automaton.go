@@ -0,0 +1,316 @@
+package gdpgen
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// lrItem is the core of an LR(1) item: a product id and a dot position.
+type lrItem struct {
+	prod int
+	dot  int
+}
+
+// lrState is a state of the LR automaton. Its kernel identifies it; the
+// closure is derived from the kernel whenever the state is processed.
+type lrState struct {
+	kernel     []lrItem
+	lookaheads []bitset // parallel to kernel
+	closure    []lrItem
+	closureLAs []bitset // parallel to closure
+}
+
+type lrAutomaton struct {
+	idx         *grammarIndex
+	mode        TableMode
+	states      []*lrState
+	transitions []map[int]int // symbol id -> state, per state
+}
+
+// buildAutomaton constructs the LR automaton of idx in a single worklist
+// pass. Successor kernels are looked up by a hash of their core; whether a
+// successor is folded into an existing state with the same core depends on
+// mode. A state that gains lookaheads is queued again so that its
+// successors receive them too.
+func buildAutomaton(idx *grammarIndex, mode TableMode) *lrAutomaton {
+	a := &lrAutomaton{idx: idx, mode: mode}
+	byCore := make(map[string][]int)
+
+	startLA := newBitset(idx.nTerms)
+	startLA.add(idx.ids[eot])
+	start := &lrState{
+		kernel:     []lrItem{{idx.prodIDs[idx.augProduct()], 0}},
+		lookaheads: []bitset{startLA},
+	}
+	a.states = []*lrState{start}
+	a.transitions = []map[int]int{make(map[int]int)}
+	byCore[a.stateKey(start)] = []int{0}
+
+	queued := []bool{true}
+	work := []int{0}
+	for len(work) > 0 {
+		i := work[0]
+		work = work[1:]
+		queued[i] = false
+
+		state := a.states[i]
+		state.close(idx)
+		for _, succ := range state.successors(idx) {
+			key := a.stateKey(succ.state)
+			j := -1
+			for _, candidate := range byCore[key] {
+				if a.mergeable(a.states[candidate], succ.state) {
+					j = candidate
+					break
+				}
+			}
+			if j < 0 {
+				j = len(a.states)
+				a.states = append(a.states, succ.state)
+				a.transitions = append(a.transitions, make(map[int]int))
+				byCore[key] = append(byCore[key], j)
+				queued = append(queued, true)
+				work = append(work, j)
+			} else if a.states[j].mergeLookaheads(succ.state) && !queued[j] {
+				queued[j] = true
+				work = append(work, j)
+			}
+			a.transitions[i][succ.symbol] = j
+		}
+	}
+
+	a.pruneUnreachable()
+	return a
+}
+
+// stateKey hashes the core of st, plus its lookaheads in canonical mode
+// where only identical states are merged.
+func (a *lrAutomaton) stateKey(st *lrState) string {
+	key := coreKey(st.kernel)
+	if a.mode == CANONICAL_LR1 {
+		for _, la := range st.lookaheads {
+			key += la.key()
+		}
+	}
+	return key
+}
+
+func (a *lrAutomaton) mergeable(state, succ *lrState) bool {
+	switch a.mode {
+	case LALR1:
+		return true
+	case PAGER_LR1:
+		return state.weaklyCompatible(succ)
+	}
+	for k := range state.lookaheads {
+		if !state.lookaheads[k].subsetOf(succ.lookaheads[k]) ||
+			!succ.lookaheads[k].subsetOf(state.lookaheads[k]) {
+			return false
+		}
+	}
+	return true
+}
+
+// close computes the closure of the kernel, propagating lookaheads with a
+// worklist of the items whose lookaheads grew.
+func (st *lrState) close(idx *grammarIndex) {
+	st.closure = make([]lrItem, len(st.kernel))
+	st.closureLAs = make([]bitset, len(st.kernel))
+	pos := make(map[lrItem]int)
+	work := []int{}
+	for k, it := range st.kernel {
+		st.closure[k] = it
+		st.closureLAs[k] = st.lookaheads[k].clone()
+		pos[it] = k
+		work = append(work, k)
+	}
+	for len(work) > 0 {
+		k := work[len(work)-1]
+		work = work[:len(work)-1]
+		it := st.closure[k]
+		body := idx.bodies[it.prod]
+		if it.dot >= len(body) || idx.isTerminal(body[it.dot]) {
+			continue
+		}
+		la := idx.firstAfter[it.prod][it.dot+1].clone()
+		if idx.nullableAfter[it.prod][it.dot+1] {
+			la.union(st.closureLAs[k])
+		}
+		for _, p := range idx.prodsOf[body[it.dot]] {
+			next := lrItem{p, 0}
+			if n, ok := pos[next]; ok {
+				if st.closureLAs[n].union(la) {
+					work = append(work, n)
+				}
+				continue
+			}
+			pos[next] = len(st.closure)
+			st.closure = append(st.closure, next)
+			st.closureLAs = append(st.closureLAs, la.clone())
+			work = append(work, len(st.closure)-1)
+		}
+	}
+}
+
+type lrSuccessor struct {
+	symbol int
+	state  *lrState
+}
+
+// successors returns the kernel reached on every symbol after the dot of
+// the closure, ordered by symbol id.
+func (st *lrState) successors(idx *grammarIndex) []lrSuccessor {
+	bySymbol := make(map[int]*lrState)
+	symbols := []int{}
+	for k, it := range st.closure {
+		body := idx.bodies[it.prod]
+		if it.dot >= len(body) {
+			continue
+		}
+		sym := body[it.dot]
+		succ, ok := bySymbol[sym]
+		if !ok {
+			succ = &lrState{}
+			bySymbol[sym] = succ
+			symbols = append(symbols, sym)
+		}
+		next := lrItem{it.prod, it.dot + 1}
+		found := false
+		for n, kernelItem := range succ.kernel {
+			if kernelItem == next {
+				succ.lookaheads[n].union(st.closureLAs[k])
+				found = true
+				break
+			}
+		}
+		if !found {
+			succ.kernel = append(succ.kernel, next)
+			succ.lookaheads = append(succ.lookaheads, st.closureLAs[k].clone())
+		}
+	}
+
+	sort.Ints(symbols)
+	successors := make([]lrSuccessor, len(symbols))
+	for i, sym := range symbols {
+		succ := bySymbol[sym]
+		sort.Sort(byItem{succ.kernel, succ.lookaheads})
+		successors[i] = lrSuccessor{sym, succ}
+	}
+	return successors
+}
+
+// mergeLookaheads adds the lookaheads of o, which has the same core, and
+// reports whether st changed.
+func (st *lrState) mergeLookaheads(o *lrState) bool {
+	changed := false
+	for k := range st.lookaheads {
+		if st.lookaheads[k].union(o.lookaheads[k]) {
+			changed = true
+		}
+	}
+	return changed
+}
+
+// weaklyCompatible implements Pager's weak compatibility test for two
+// kernels with the same core: merging them cannot create a reduce/reduce
+// conflict that neither has on its own. For every pair of distinct items
+// i, j with lookaheads Ai, Aj in st and Bi, Bj in o, either Ai∩Bj and Bi∩Aj
+// are empty, or Ai∩Aj or Bi∩Bj is not.
+func (st *lrState) weaklyCompatible(o *lrState) bool {
+	a, b := st.lookaheads, o.lookaheads
+	for i := range a {
+		for j := i + 1; j < len(a); j++ {
+			if !a[i].intersects(b[j]) && !b[i].intersects(a[j]) {
+				continue
+			}
+			if !a[i].intersects(a[j]) && !b[i].intersects(b[j]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pruneUnreachable drops the states no transition leads to any more, which
+// happens when a merged state moves its successors elsewhere, and numbers
+// the remaining states in breadth-first order.
+func (a *lrAutomaton) pruneUnreachable() {
+	renumber := map[int]int{0: 0}
+	order := []int{0}
+	for k := 0; k < len(order); k++ {
+		i := order[k]
+		for _, sym := range sortedKeys(a.transitions[i]) {
+			j := a.transitions[i][sym]
+			if _, ok := renumber[j]; !ok {
+				renumber[j] = len(order)
+				order = append(order, j)
+			}
+		}
+	}
+	if len(order) == len(a.states) {
+		return
+	}
+
+	states := make([]*lrState, len(order))
+	transitions := make([]map[int]int, len(order))
+	for k, i := range order {
+		states[k] = a.states[i]
+		transitions[k] = make(map[int]int)
+		for sym, j := range a.transitions[i] {
+			transitions[k][sym] = renumber[j]
+		}
+	}
+	a.states = states
+	a.transitions = transitions
+}
+
+func sortedKeys(m map[int]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+func coreKey(kernel []lrItem) string {
+	var sb strings.Builder
+	for _, it := range kernel {
+		sb.WriteString(strconv.Itoa(it.prod))
+		sb.WriteByte('.')
+		sb.WriteString(strconv.Itoa(it.dot))
+		sb.WriteByte(' ')
+	}
+	return sb.String()
+}
+
+type byItem struct {
+	items      []lrItem
+	lookaheads []bitset
+}
+
+func (s byItem) Len() int {
+	return len(s.items)
+}
+
+func (s byItem) Less(i, j int) bool {
+	if s.items[i].prod != s.items[j].prod {
+		return s.items[i].prod < s.items[j].prod
+	}
+	return s.items[i].dot < s.items[j].dot
+}
+
+func (s byItem) Swap(i, j int) {
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+	s.lookaheads[i], s.lookaheads[j] = s.lookaheads[j], s.lookaheads[i]
+}
+
+func (idx *grammarIndex) augProduct() *Product {
+	for _, p := range idx.products {
+		if p.Head == augStartElem {
+			return p
+		}
+	}
+	return nil
+}
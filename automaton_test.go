@@ -0,0 +1,93 @@
+package gdpgen
+
+import (
+	"fmt"
+	"testing"
+)
+
+// newLargeGrammar returns a conflict-free grammar of about 300 products:
+// statements over an expression grammar with forty precedence levels, and
+// a family of record declarations.
+func newLargeGrammar() *G {
+	const levels, keywords, records = 40, 60, 37
+	t := NewTerminal
+	e := func(level int) *ProductElem {
+		return NewNonTerminal(fmt.Sprintf("e%v", level))
+	}
+	program := NewNonTerminal("program")
+	items := NewNonTerminal("items")
+	item := NewNonTerminal("item")
+	stmt := NewNonTerminal("stmt")
+	block := NewNonTerminal("block")
+	stmts := NewNonTerminal("stmts")
+	args := NewNonTerminal("args")
+	argList := NewNonTerminal("argList")
+
+	g := NewGrammar(program)
+	add := func(head *ProductElem, body ...*ProductElem) {
+		if len(body) == 0 {
+			body = []*ProductElem{EmptyElem}
+		}
+		g.AddProduct(&Product{head, body, nil})
+	}
+	add(program, items)
+	add(items)
+	add(items, items, item)
+	add(item, stmt)
+	add(stmt, block)
+	add(block, t("{"), stmts, t("}"))
+	add(stmts)
+	add(stmts, stmts, stmt)
+	add(stmt, t("if"), t("("), e(0), t(")"), block)
+	add(stmt, t("while"), t("("), e(0), t(")"), block)
+	add(stmt, t("return"), e(0), t(";"))
+	for k := 0; k < keywords; k++ {
+		add(stmt, t(fmt.Sprintf("kw%v", k)), t("id"), t("="), e(0), t(";"))
+	}
+
+	for i := 0; i < levels; i++ {
+		add(e(i), e(i), t(fmt.Sprintf("op%va", i)), e(i+1))
+		add(e(i), e(i), t(fmt.Sprintf("op%vb", i)), e(i+1))
+		add(e(i), e(i+1))
+	}
+	add(e(levels), t("("), e(0), t(")"))
+	add(e(levels), t("id"))
+	add(e(levels), t("num"))
+	add(e(levels), t("id"), t("("), args, t(")"))
+	add(args)
+	add(args, argList)
+	add(argList, e(0))
+	add(argList, argList, t(","), e(0))
+
+	for r := 0; r < records; r++ {
+		fields := NewNonTerminal(fmt.Sprintf("fields%v", r))
+		add(item, t(fmt.Sprintf("record%v", r)), t("id"), t("{"), fields, t("}"))
+		add(fields)
+		add(fields, fields, t("id"), t(";"))
+	}
+	return g
+}
+
+func benchmarkCompile(b *testing.B, mode TableMode) {
+	g := newLargeGrammar()
+	if _, err := Compile(g, WithTableMode(mode), Strict()); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		Compile(g, WithTableMode(mode))
+	}
+}
+
+func BenchmarkCompileCanonicalLR1(b *testing.B) {
+	benchmarkCompile(b, CANONICAL_LR1)
+}
+
+func BenchmarkCompileLALR1(b *testing.B) {
+	benchmarkCompile(b, LALR1)
+}
+
+func BenchmarkCompilePagerLR1(b *testing.B) {
+	benchmarkCompile(b, PAGER_LR1)
+}
@@ -0,0 +1,340 @@
+// Command gdpgen checks grammar files, dumps their parsing tables and LR
+// automata, generates Go parsers from them and runs them on input.
+//
+//	gdpgen check [-mode m] [-strict] grammar
+//	gdpgen tables [-mode m] grammar
+//	gdpgen dot [-mode m] grammar | dot -Tsvg > automaton.svg
+//	gdpgen report [-mode m] [-html] grammar
+//	gdpgen gen [-mode m] [-o file] [-package name] [-prefix Yy] grammar
+//	gdpgen parse [-mode m] grammar input
+//
+// The mode is one of canonical, lalr and pager. gen writes to standard
+// output unless -o is given, and defaults the package to $GOPACKAGE, so
+// that it can be used as
+//
+//	//go:generate gdpgen gen -o parser.go calc.grammar
+//
+// The exit status is 0 on success, 1 if the grammar has errors, its
+// conflicts do not match %expect (or, with -strict, it has any unresolved
+// conflict) or the input does not parse, and 2 on usage or I/O errors.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"gdpgen"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	exitOK = iota
+	exitFail
+	exitUsage
+)
+
+const usage = `usage:
+	gdpgen check [-mode m] [-strict] grammar
+	gdpgen tables [-mode m] grammar
+	gdpgen dot [-mode m] grammar
+	gdpgen report [-mode m] [-html] grammar
+	gdpgen gen [-mode m] [-o file] [-package name] [-prefix Yy] grammar
+	gdpgen parse [-mode m] grammar input
+`
+
+var modes = map[string]gdpgen.TableMode{
+	"canonical": gdpgen.CANONICAL_LR1,
+	"lalr":      gdpgen.LALR1,
+	"pager":     gdpgen.PAGER_LR1,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(exitUsage)
+	}
+	commands := map[string]func([]string) int{
+		"check":  check,
+		"tables": tables,
+		"dot":    dot,
+		"report": writeReport,
+		"gen":    gen,
+		"parse":  parse,
+	}
+	command, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "gdpgen: unknown command %q\n%v", os.Args[1], usage)
+		os.Exit(exitUsage)
+	}
+	os.Exit(command(os.Args[2:]))
+}
+
+// command holds the flags shared by all commands.
+type command struct {
+	flags *flag.FlagSet
+	mode  *string
+}
+
+func newCommand(name string) *command {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+	}
+	return &command{flags, flags.String("mode", "canonical", "LR construction: canonical, lalr or pager")}
+}
+
+// parse parses args and returns the positional arguments, which must be n.
+func (c *command) parse(args []string, n int) ([]string, error) {
+	if err := c.flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if c.flags.NArg() != n {
+		return nil, errors.New(fmt.Sprintf("%v expects %v arguments", c.flags.Name(), n))
+	}
+	if _, ok := modes[*c.mode]; !ok {
+		return nil, errors.New(fmt.Sprintf("unknown mode %q", *c.mode))
+	}
+	return c.flags.Args(), nil
+}
+
+func (c *command) options() []gdpgen.Option {
+	return []gdpgen.Option{gdpgen.WithTableMode(modes[*c.mode])}
+}
+
+// loadGrammar reads the grammar at path, reporting failures on standard
+// error together with the exit status to use: a malformed grammar fails,
+// while a file that cannot be read is a usage error.
+func loadGrammar(path string) (*gdpgen.G, int) {
+	f, err := os.Open(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return nil, exitUsage
+	}
+	defer f.Close()
+	g, err := gdpgen.ParseGrammar(f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v: %v\n", path, err)
+		if _, ok := err.(*gdpgen.GrammarError); ok {
+			return nil, exitFail
+		}
+		return nil, exitUsage
+	}
+	return g, exitOK
+}
+
+// compile loads and compiles the grammar at path, reporting failures on
+// standard error together with the exit status to use.
+func (c *command) compile(path string, opts ...gdpgen.Option) (*gdpgen.CompiledGrammar, int) {
+	g, status := loadGrammar(path)
+	if g == nil {
+		return nil, status
+	}
+	cg, err := gdpgen.Compile(g, append(c.options(), opts...)...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v: %v\n", path, err)
+		return nil, exitFail
+	}
+	return cg, exitOK
+}
+
+func check(args []string) int {
+	c := newCommand("check")
+	strict := c.flags.Bool("strict", false, "fail on any conflict not resolved by precedence or %expect")
+	files, err := c.parse(args, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	g, status := loadGrammar(files[0])
+	if g == nil {
+		return status
+	}
+
+	for _, d := range g.Validate() {
+		fmt.Printf("%v: %v\n", files[0], d)
+		if d.Severity == gdpgen.SEVERITY_ERROR {
+			status = exitFail
+		}
+	}
+	if status != exitOK {
+		return status
+	}
+
+	opts := c.options()
+	if *strict {
+		opts = append(opts, gdpgen.Strict())
+	}
+	var conflicts []*gdpgen.Conflict
+	cg, err := gdpgen.Compile(g, opts...)
+	if ce, ok := err.(*gdpgen.ConflictError); ok {
+		conflicts = ce.Conflicts
+		status = exitFail
+	} else if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v: %v\n", files[0], err)
+		return exitFail
+	} else {
+		conflicts = cg.Conflicts()
+	}
+
+	sr, rr := 0, 0
+	for _, conflict := range conflicts {
+		if conflict.ByPrecedence {
+			continue
+		}
+		if conflict.Kind == gdpgen.SHIFT_REDUCE {
+			sr++
+		} else {
+			rr++
+		}
+		fmt.Printf("%v: %v\n", files[0], conflict)
+		if conflict.Counterexample != nil {
+			fmt.Printf("  %v\n", conflict.Counterexample)
+		}
+	}
+	fmt.Printf("%v: %v shift/reduce, %v reduce/reduce conflicts\n", files[0], sr, rr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v: %v\n", files[0], err)
+	}
+	return status
+}
+
+func tables(args []string) int {
+	c := newCommand("tables")
+	files, err := c.parse(args, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	cg, status := c.compile(files[0])
+	if cg == nil {
+		return status
+	}
+	if err := cg.WriteTables(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func dot(args []string) int {
+	c := newCommand("dot")
+	files, err := c.parse(args, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	cg, status := c.compile(files[0])
+	if cg == nil {
+		return status
+	}
+	if err := cg.WriteDot(os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func writeReport(args []string) int {
+	c := newCommand("report")
+	html := c.flags.Bool("html", false, "write an HTML page")
+	files, err := c.parse(args, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	cg, status := c.compile(files[0])
+	if cg == nil {
+		return status
+	}
+	if *html {
+		err = cg.WriteHTMLReport(os.Stdout)
+	} else {
+		err = cg.WriteReport(os.Stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+func gen(args []string) int {
+	c := newCommand("gen")
+	out := c.flags.String("o", "", "output file (default standard output)")
+	pkg := c.flags.String("package", os.Getenv("GOPACKAGE"), "package of the generated file (default $GOPACKAGE, or main)")
+	prefix := c.flags.String("prefix", "Yy", "prefix of the generated names")
+	files, err := c.parse(args, 1)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	cg, status := c.compile(files[0])
+	if cg == nil {
+		return status
+	}
+
+	var src bytes.Buffer
+	if err := cg.GenerateGo(&src, *pkg, *prefix); err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	if *out == "" {
+		_, err = io.Copy(os.Stdout, &src)
+	} else {
+		err = ioutil.WriteFile(*out, src.Bytes(), 0644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	return exitOK
+}
+
+// parse prints the syntax tree the deterministic parser builds for the
+// input, and reports on standard error the parts of the input that the
+// grammar also derives differently.
+func parse(args []string) int {
+	c := newCommand("parse")
+	files, err := c.parse(args, 2)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	input, err := ioutil.ReadFile(files[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v\n", err)
+		return exitUsage
+	}
+	cg, status := c.compile(files[0])
+	if cg == nil {
+		return status
+	}
+
+	parser := cg.NewParser(cg.NewLexer())
+	tree, err := parser.ParseTree(string(input))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "gdpgen: %v: %v\n", files[1], strings.TrimSpace(err.Error()))
+		return exitFail
+	}
+	fmt.Print(tree)
+
+	// the tree follows the conflict resolutions of the table; tell where
+	// the grammar would also allow others
+	forest, err := parser.ParseForest(string(input))
+	if err != nil {
+		return exitOK
+	}
+	for _, n := range forest.AmbiguousNodes() {
+		span := forest.Span(n)
+		fmt.Fprintf(os.Stderr, "gdpgen: %v:%v:%v: %v is ambiguous, %v derivations\n",
+			files[1], span.Line, span.Column, n.Symbol.Sig, len(n.Alternatives))
+	}
+	return exitOK
+}
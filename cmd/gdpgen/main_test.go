@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when the test binary is started
+// by gdpgen, so that the commands run with their exit status.
+func TestMain(m *testing.M) {
+	if os.Getenv("GDPGEN_TEST_MAIN") == "1" {
+		main()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// run runs gdpgen with args and returns its exit status and
+// outputs.
+func run(t *testing.T, args ...string) (int, string, string) {
+	cmd := exec.Command(os.Args[0], args...)
+	cmd.Env = append(os.Environ(), "GDPGEN_TEST_MAIN=1")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	err := cmd.Run()
+	if exit, ok := err.(*exec.ExitError); ok {
+		return exit.ExitCode(), stdout.String(), stderr.String()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	return 0, stdout.String(), stderr.String()
+}
+
+// writeFiles writes each file of files to a temporary directory, and
+// returns the paths by name.
+func writeFiles(t *testing.T, files map[string]string) map[string]string {
+	dir := t.TempDir()
+	paths := make(map[string]string)
+	for name, content := range files {
+		paths[name] = filepath.Join(dir, name)
+		if err := ioutil.WriteFile(paths[name], []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	paths["missing"] = filepath.Join(dir, "missing")
+	return paths
+}
+
+const calcGrammar = `
+%token num /\d+/
+%left "+"
+%left "*"
+e : e "+" e {add} | e "*" e {mul} | num ;
+`
+
+func TestCommands(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"calc.grammar":      calcGrammar,
+		"ambiguous.grammar": "%token num /\\d+/\ne : e \"-\" e | num ;",
+		"cyclic.grammar":    `s : a | "x" ; a : s ;`,
+		"malformed.grammar": `s : "a" @ ;`,
+		"calc.txt":          "1+2*3",
+		"error.txt":         "1 + * 2",
+	})
+	tests := []struct {
+		args   []string
+		status int
+		// stdout and stderr are substrings of the outputs
+		stdout, stderr string
+	}{
+		{[]string{"check", files["calc.grammar"]}, 0, "0 shift/reduce, 0 reduce/reduce conflicts", ""},
+		{[]string{"check", files["ambiguous.grammar"]}, 0, "1 shift/reduce, 0 reduce/reduce conflicts", ""},
+		{[]string{"check", "-strict", files["ambiguous.grammar"]}, 1, "shift/reduce conflict on -", "expected 0 and 0"},
+		{[]string{"check", files["cyclic.grammar"]}, 1, "cyclic derivation", ""},
+		{[]string{"check", files["malformed.grammar"]}, 1, "", "unexpected character '@'"},
+		{[]string{"check", files["missing"]}, 2, "", "no such file"},
+		{[]string{"check", "-mode", "slr", files["calc.grammar"]}, 2, "", `unknown mode "slr"`},
+		{[]string{"check"}, 2, "", "check expects 1 arguments"},
+		{[]string{"compile", files["calc.grammar"]}, 2, "", `unknown command "compile"`},
+		{[]string{"tables", "-mode", "lalr", files["calc.grammar"]}, 0, "", ""},
+		{[]string{"dot", files["calc.grammar"]}, 0, "digraph automaton {", ""},
+		{[]string{"report", files["calc.grammar"]}, 0, "Grammar (canonical LR(1))", ""},
+		{[]string{"report", "-html", files["calc.grammar"]}, 0, "<!DOCTYPE html>", ""},
+		{[]string{"report", files["cyclic.grammar"]}, 1, "", "cyclic derivation"},
+		{[]string{"gen", "-package", "calc", files["calc.grammar"]}, 0, "package calc", ""},
+		{[]string{"gen", "-prefix", "yy", files["calc.grammar"]}, 2, "", "prefix"},
+		{[]string{"parse", files["calc.grammar"], files["calc.txt"]}, 0,
+			"e [0:5] {add}\n  e [0:1]\n    num \"1\" [0:1]\n  + \"+\" [1:2]\n  e [2:5] {mul}\n", ""},
+		{[]string{"parse", files["calc.grammar"], files["error.txt"]}, 1, "", "invalid syntax"},
+		{[]string{"parse", files["calc.grammar"], files["missing"]}, 2, "", "no such file"},
+		{[]string{"parse", files["calc.grammar"]}, 2, "", "parse expects 2 arguments"},
+	}
+	for _, tc := range tests {
+		status, stdout, stderr := run(t, tc.args...)
+		if status != tc.status || !strings.Contains(stdout, tc.stdout) || !strings.Contains(stderr, tc.stderr) {
+			t.Errorf("gdpgen %v: status %v, stdout:\n%v\nstderr:\n%v\nwant status %v, %q and %q",
+				strings.Join(tc.args, " "), status, stdout, stderr, tc.status, tc.stdout, tc.stderr)
+		}
+	}
+}
+
+func TestGenOutput(t *testing.T) {
+	files := writeFiles(t, map[string]string{"calc.grammar": calcGrammar})
+	out := filepath.Join(filepath.Dir(files["calc.grammar"]), "parser.go")
+	if status, _, stderr := run(t, "gen", "-o", out, "-package", "calc", "-prefix", "Calc", files["calc.grammar"]); status != 0 {
+		t.Fatalf("gen: status %v: %v", status, stderr)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), out, nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name.Name != "calc" || f.Scope.Lookup("CalcParse") == nil {
+		t.Errorf("%v declares package %v without CalcParse", out, f.Name.Name)
+	}
+}
+
+func TestParseAmbiguous(t *testing.T) {
+	files := writeFiles(t, map[string]string{
+		"ambiguous.grammar": "%token num /\\d+/\ne : e \"-\" e | num ;",
+		"ambiguous.txt":     "8 - 4 - 2",
+	})
+	status, stdout, stderr := run(t, "parse", files["ambiguous.grammar"], files["ambiguous.txt"])
+	if status != 0 {
+		t.Fatalf("status %v: %v", status, stderr)
+	}
+	// the conflict is resolved by shifting, so - groups to the right
+	want := "e [0:9]\n  e [0:1]\n    num \"8\" [0:1]\n  - \"-\" [2:3]\n  e [4:9]\n"
+	if !strings.HasPrefix(stdout, want) {
+		t.Errorf("tree:\n%v\nwant it to start with\n%v", stdout, want)
+	}
+	if want := "ambiguous.txt:1:1: e is ambiguous, 2 derivations\n"; !strings.HasSuffix(stderr, want) {
+		t.Errorf("stderr %q, want %q", stderr, want)
+	}
+}
@@ -0,0 +1,339 @@
+package gdpgen
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// GenerateGo writes a Go source file of package pkg holding the tables of cg
+// as static arrays, a lexer for the patterns of the grammar that, like
+// RegexLexer, skips white space and unmatched characters, and an LR driver.
+// The generated code only depends on the standard library. Its exported
+// names start with prefix, e.g. with "Yy":
+//
+//	func YyParse(lex YyLexer, actions map[string]YyAction) (interface{}, error)
+//	func NewYyLexer(input string) YyLexer
+//
+// The actions are keyed by product label, or, for products without one, by
+// the head and body symbol names of the product ("e : e + e");
+// YyProductKeys lists the keys. As with Parser.Parse, a product without an
+// action yields the values of its body. Conflicts are resolved as in cg.
+// A YyToken has the fields of a Token, with the fields of its Span inline.
+func (cg *CompiledGrammar) GenerateGo(w io.Writer, pkg, prefix string) error {
+	if prefix == "" || !unicode.IsUpper([]rune(prefix)[0]) {
+		return errors.New(fmt.Sprintf("prefix %q must start with an upper case letter", prefix))
+	}
+	idx := cg.augG.analysis()
+
+	nStates := cg.numStates()
+
+	accept := -1
+	keys := []string{}
+	for i, p := range idx.products {
+		if p.Head == augStartElem {
+			accept = i
+		}
+		keys = append(keys, productKey(cg.augG, p))
+	}
+
+	actions := make([][]int, nStates)
+	gotos := make([][]int, nStates)
+	for state := 0; state < nStates; state++ {
+		actions[state] = make([]int, idx.nTerms)
+		for term, act := range cg.actionTable.states[state] {
+			switch act.op {
+			case shiftAction:
+				actions[state][idx.ids[term]] = act.state + 1
+			case reduceAction:
+				actions[state][idx.ids[term]] = -idx.prodIDs[act.prod] - 1
+			case acceptAction:
+				actions[state][idx.ids[term]] = -accept - 1
+			}
+		}
+		gotos[state] = make([]int, len(idx.symbols)-idx.nTerms)
+		for i := range gotos[state] {
+			gotos[state][i] = -1
+		}
+		for head, to := range cg.gotoTable.states[state] {
+			gotos[state][idx.ids[head]-idx.nTerms] = to
+		}
+	}
+
+	bodies := make([][]int, len(idx.products))
+	for i, p := range idx.products {
+		bodies[i] = []int{}
+		for _, elem := range p.Body {
+			if elem == EmptyElem {
+				bodies[i] = append(bodies[i], -1)
+			} else {
+				bodies[i] = append(bodies[i], idx.ids[elem])
+			}
+		}
+	}
+
+	patterns := [][2]string{}
+	for _, p := range cg.augG.patterns {
+		patterns = append(patterns, [2]string{p.Name, p.Regex.String()})
+	}
+
+	lower := []rune(prefix)
+	lower[0] = unicode.ToLower(lower[0])
+	data := map[string]interface{}{
+		"Package":     pkg,
+		"P":           prefix,
+		"L":           string(lower),
+		"Fingerprint": cg.fingerprint,
+		"Symbols":     idx.symbols,
+		"NTerms":      idx.nTerms,
+		"Heads":       idx.heads,
+		"Bodies":      bodies,
+		"Keys":        keys,
+		"Accept":      accept,
+		"Actions":     actions,
+		"Gotos":       gotos,
+		"Patterns":    patterns,
+	}
+	var buf bytes.Buffer
+	if err := goTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(src)
+	return err
+}
+
+// productKey returns the label of p, or its head and body symbol names.
+func productKey(g *G, p *Product) string {
+	if label := g.Label(p); label != "" {
+		return label
+	}
+	words := []string{p.Head.Sig, ":"}
+	for _, elem := range p.Body {
+		words = append(words, symbolName(elem))
+	}
+	return strings.Join(words, " ")
+}
+
+var goTemplate = template.Must(template.New("parser").Funcs(template.FuncMap{
+	"quote": strconv.Quote,
+	"ints": func(row []int) string {
+		s := make([]string, len(row))
+		for i, v := range row {
+			s[i] = strconv.Itoa(v)
+		}
+		return strings.Join(s, ", ")
+	},
+}).Parse(`// Code generated by gdpgen from grammar {{.Fingerprint}}. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// {{.P}}Token is a token read by a {{.P}}Lexer. The end of input is the
+// token named "$". Start and End are the byte offsets of Value in the
+// input; lines and columns count from 1, columns in runes, and the end is
+// exclusive.
+type {{.P}}Token struct {
+	Name      string
+	Value     string
+	Start     int
+	End       int
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+// {{.P}}Lexer reads the tokens of an input.
+type {{.P}}Lexer interface {
+	Next() {{.P}}Token
+}
+
+// {{.P}}Action computes the value of a product from the values of its body.
+type {{.P}}Action func(values []interface{}) interface{}
+
+// {{.P}}Symbols are the names of the symbols by id. Terminals come first.
+var {{.P}}Symbols = []string{
+{{- range .Symbols}}
+	{{quote .Sig}},
+{{- end}}
+}
+
+// {{.P}}ProductKeys are the keys of the products in the actions of {{.P}}Parse.
+var {{.P}}ProductKeys = []string{
+{{- range .Keys}}
+	{{quote .}},
+{{- end}}
+}
+
+const {{.L}}NumTerminals = {{.NTerms}}
+
+const {{.L}}Accept = {{.Accept}}
+
+var {{.L}}Terminals = map[string]int{
+{{- range $i, $sym := .Symbols}}{{if lt $i $.NTerms}}
+	{{quote $sym.Sig}}: {{$i}},
+{{- end}}{{end}}
+}
+
+var {{.L}}ProductHeads = []int{ {{- ints .Heads -}} }
+
+// {{.L}}ProductBodies holds the symbol ids of every body, -1 for %empty.
+var {{.L}}ProductBodies = [][]int{
+{{- range .Bodies}}
+	{ {{- ints .}}},
+{{- end}}
+}
+
+// {{.L}}ActionTable holds, by state and terminal, s+1 to shift to state s,
+// -p-1 to reduce by product p, or 0 for a syntax error. Reducing by
+// {{.L}}Accept accepts.
+var {{.L}}ActionTable = [][]int{
+{{- range .Actions}}
+	{ {{- ints .}}},
+{{- end}}
+}
+
+// {{.L}}GotoTable holds, by state and non-terminal, the state to go to or -1.
+var {{.L}}GotoTable = [][]int{
+{{- range .Gotos}}
+	{ {{- ints .}}},
+{{- end}}
+}
+
+var {{.L}}Patterns = []struct {
+	name string
+	re   *regexp.Regexp
+}{
+{{- range .Patterns}}
+	{ {{- quote (index . 0)}}, regexp.MustCompile({{quote (index . 1)}})},
+{{- end}}
+}
+
+type {{.L}}Lexer struct {
+	input  string
+	pos    int
+	line   int
+	column int
+}
+
+// New{{.P}}Lexer returns a lexer for input that skips white space and tries
+// the patterns of the grammar in order. Like the RegexLexer of gdpgen, it
+// skips characters that no pattern matches.
+func New{{.P}}Lexer(input string) {{.P}}Lexer {
+	return &{{.L}}Lexer{input, 0, 1, 1}
+}
+
+func (l *{{.L}}Lexer) Next() {{.P}}Token {
+	for l.pos < len(l.input) {
+		switch l.input[l.pos] {
+		case ' ', '\t', '\r', '\n':
+			l.advance(1)
+			continue
+		}
+		rest := l.input[l.pos:]
+		for _, p := range {{.L}}Patterns {
+			if m := p.re.FindStringIndex(rest); m != nil && m[1] > 0 {
+				start, line, column := l.pos, l.line, l.column
+				l.advance(m[1])
+				return {{.P}}Token{p.name, rest[:m[1]], start, l.pos, line, column, l.line, l.column}
+			}
+		}
+		// characters no pattern matches are skipped
+		_, size := utf8.DecodeRuneInString(rest)
+		l.advance(size)
+	}
+	return {{.P}}Token{"$", "", l.pos, l.pos, l.line, l.column, l.line, l.column}
+}
+
+func (l *{{.L}}Lexer) advance(n int) {
+	for _, c := range l.input[l.pos : l.pos+n] {
+		if c == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+	}
+	l.pos += n
+}
+
+// {{.P}}Parse parses the tokens of lex. The value of every product is
+// computed by its action in actions, keyed as in {{.P}}ProductKeys; a
+// product without an action yields the values of its body.
+func {{.P}}Parse(lex {{.P}}Lexer, actions map[string]{{.P}}Action) (interface{}, error) {
+	stack := []int{0}
+	values := []interface{}{}
+	token := lex.Next()
+	a, ok := {{.L}}Terminals[token.Name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown token %q at line:%v, column:%v", token.Value, token.Line, token.Column))
+	}
+	for {
+		s := stack[len(stack)-1]
+		act := {{.L}}ActionTable[s][a]
+		switch {
+		case act > 0:
+			stack = append(stack, act-1)
+			values = append(values, token)
+			token = lex.Next()
+			a, ok = {{.L}}Terminals[token.Name]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("unknown token %q at line:%v, column:%v", token.Value, token.Line, token.Column))
+			}
+		case act < 0:
+			prod := -act - 1
+			if prod == {{.L}}Accept {
+				return values[len(values)-1], nil
+			}
+			body := {{.L}}ProductBodies[prod]
+			n := 0
+			for _, sym := range body {
+				if sym != -1 {
+					n++
+				}
+			}
+			popped := values[len(values)-n:]
+			args := make([]interface{}, 0, len(body))
+			for _, sym := range body {
+				if sym == -1 {
+					args = append(args, nil)
+				} else {
+					args = append(args, popped[0])
+					popped = popped[1:]
+				}
+			}
+			var value interface{} = args
+			if action, ok := actions[{{.P}}ProductKeys[prod]]; ok && action != nil {
+				value = action(args)
+			}
+			values = append(values[:len(values)-n], value)
+			stack = stack[:len(stack)-n]
+			stack = append(stack, {{.L}}GotoTable[stack[len(stack)-1]][{{.L}}ProductHeads[prod]-{{.L}}NumTerminals])
+		default:
+			expects := []string{}
+			for term, act := range {{.L}}ActionTable[s] {
+				if act != 0 {
+					expects = append(expects, {{.P}}Symbols[term])
+				}
+			}
+			return nil, errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. expects one of %q, but actual %q", token.Line, token.Column, expects, token.Value))
+		}
+	}
+}
+`))
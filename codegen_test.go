@@ -0,0 +1,145 @@
+package gdpgen
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateGo(t *testing.T) {
+	cg, err := Compile(newCalcGrammar(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var src bytes.Buffer
+	if err := cg.GenerateGo(&src, "calc", "Calc"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := parser.ParseFile(token.NewFileSet(), "parser.go", src.Bytes(), 0)
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v", err)
+	}
+	if f.Name.Name != "calc" {
+		t.Errorf("package %v, want calc", f.Name.Name)
+	}
+	for _, name := range []string{"CalcParse", "NewCalcLexer", "CalcProductKeys", "CalcToken"} {
+		if f.Scope.Lookup(name) == nil {
+			t.Errorf("%v is not declared", name)
+		}
+	}
+	for _, imp := range f.Imports {
+		if bytes.Contains([]byte(imp.Path.Value), []byte("gdpgen")) {
+			t.Errorf("generated code imports %v", imp.Path.Value)
+		}
+	}
+
+	for _, prefix := range []string{"", "yy"} {
+		if err := cg.GenerateGo(&bytes.Buffer{}, "calc", prefix); err == nil {
+			t.Errorf("GenerateGo accepted prefix %q", prefix)
+		}
+	}
+}
+
+const calcMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+func main() {
+	binary := func(op func(a, b int) int) CalcAction {
+		return func(values []interface{}) interface{} {
+			return op(values[0].(int), values[2].(int))
+		}
+	}
+	actions := map[string]CalcAction{
+		"add":   binary(func(a, b int) int { return a + b }),
+		"sub":   binary(func(a, b int) int { return a - b }),
+		"mul":   binary(func(a, b int) int { return a * b }),
+		"div":   binary(func(a, b int) int { return a / b }),
+		"paren": func(values []interface{}) interface{} { return values[1] },
+		"num": func(values []interface{}) interface{} {
+			n, _ := strconv.Atoi(values[0].(CalcToken).Value)
+			return n
+		},
+	}
+	for _, input := range os.Args[1:] {
+		value, err := CalcParse(NewCalcLexer(input), actions)
+		fmt.Println(value, err != nil)
+		lex := NewCalcLexer(input)
+		for token := lex.Next(); ; token = lex.Next() {
+			fmt.Println(token.Name, token.Value, token.Start, token.End, token.Line, token.Column, token.EndLine, token.EndColumn)
+			if token.Name == "$" {
+				break
+			}
+		}
+	}
+}
+`
+
+func TestGeneratedParserRuns(t *testing.T) {
+	goTool, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go command not found")
+	}
+	g := newCalcGrammar(t)
+	cg, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	var src bytes.Buffer
+	if err := cg.GenerateGo(&src, "main", "Calc"); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.mod":    "module calc\n\ngo 1.16\n",
+		"parser.go": src.String(),
+		"main.go":   calcMain,
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inputs := []string{"1+2*3", "(1 +\n 2) * é3", "1 + * 2"}
+	cmd := exec.Command(goTool, append([]string{"run", "."}, inputs...)...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go run: %v\n%s", err, out)
+	}
+
+	// the generated parser evaluates like Parse and its lexer reads the
+	// tokens of RegexLexer
+	var want strings.Builder
+	calc := cg.NewParser(g.NewLexer())
+	for _, input := range inputs {
+		value, err := calc.Parse(input)
+		fmt.Fprintln(&want, value, err != nil)
+		lex := g.NewLexer()
+		lex.GetReader(input)
+		for token := lex.GetNextToken(); ; token = lex.GetNextToken() {
+			fmt.Fprintln(&want, token.Name, token.Value, token.Start, token.End, token.Line, token.Column, token.EndLine, token.EndColumn)
+			if token.Name == "$" {
+				break
+			}
+		}
+	}
+	got := string(out)
+	if got != want.String() {
+		t.Errorf("generated parser printed\n%v\nwant\n%v", got, want.String())
+	}
+	if !strings.HasPrefix(got, "7 false\n") {
+		t.Errorf("1+2*3 = %v, want 7", strings.SplitN(got, "\n", 2)[0])
+	}
+}
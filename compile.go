@@ -0,0 +1,146 @@
+package gdpgen
+
+// CompiledGrammar is a snapshot of a G taken by Compile, augmented with the
+// S' product, together with its LR automaton and parsing tables. Apart from
+// attaching Callbacks to tables loaded with LoadCompiledGrammar, it is
+// never modified, so any number of Parsers can share it.
+type CompiledGrammar struct {
+	augG        *G
+	mode        TableMode
+	actionTable *actionTable
+	gotoTable   *goToTable
+	automaton   *lrAutomaton
+	fingerprint string
+}
+
+// Compile builds the parsing tables of g without modifying g. Products,
+// their Callbacks, labels and precedences are copied, so later changes to
+// g do not affect the result. Like NewParserWithOptions, it fails with a
+// ValidationError if g has errors, or with a ConflictError when the
+// conflicts do not match the expected counts.
+func Compile(g *G, opts ...Option) (*CompiledGrammar, error) {
+	if err := validate(g.Validate()); err != nil {
+		return nil, err
+	}
+	config := &parserConfig{}
+	if g.expect != nil {
+		config.expectSR, config.expectRR, config.hasExpect = g.expect[0], g.expect[1], true
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	cg := compile(g, config.mode)
+	if !config.strict && !config.hasExpect {
+		return cg, nil
+	}
+	sr, rr := countConflicts(cg.Conflicts())
+	if sr != config.expectSR || rr != config.expectRR {
+		return nil, &ConflictError{cg.Conflicts(), sr, rr, config.expectSR, config.expectRR}
+	}
+	return cg, nil
+}
+
+func compile(g *G, mode TableMode) *CompiledGrammar {
+	augG := g.snapshot()
+	augment(augG)
+	cg := &CompiledGrammar{
+		augG,
+		mode,
+		newActionTable(),
+		newGoToTable(),
+		nil,
+		g.Fingerprint(),
+	}
+	cg.constructParsingTable()
+	return cg
+}
+
+// NewParser returns a parser that reads its input with lex.
+func (cg *CompiledGrammar) NewParser(lex Lexer) *Parser {
+	return &Parser{cg, lex, nil, nil}
+}
+
+// NewParserWithLexerFactory returns a parser that gets a new lexer from
+// newLexer for every parse, so that it can be used by several goroutines
+// at once even with lexers that do not implement LexerCloner.
+func (cg *CompiledGrammar) NewParserWithLexerFactory(newLexer func() Lexer) *Parser {
+	return &Parser{cg, nil, newLexer, nil}
+}
+
+// Mode returns the construction the tables were built with.
+func (cg *CompiledGrammar) Mode() TableMode {
+	return cg.mode
+}
+
+// snapshot returns a copy of g with copies of its products, so that
+// augmenting it or building tables from it leaves g untouched.
+func (g *G) snapshot() *G {
+	s := NewGrammar(g.StartSymbol)
+	for _, p := range g.Products {
+		c := &Product{p.Head, append([]*ProductElem{}, p.Body...), p.Callback}
+		s.AddProduct(c)
+		if m, ok := g.meta[p]; ok {
+			copied := *m
+			s.meta[c] = &copied
+		}
+	}
+	s.patterns = append(s.patterns, g.patterns...)
+	for name, elem := range g.synthesized {
+		s.synthesized[name] = elem
+	}
+	for term, prec := range g.precs {
+		s.precs[term] = prec
+	}
+	if g.expect != nil {
+		expect := *g.expect
+		s.expect = &expect
+	}
+	return s
+}
+
+func (cg *CompiledGrammar) constructParsingTable() {
+	// construct the LR automaton
+	idx := cg.augG.analysis()
+	automaton := buildAutomaton(idx, cg.mode)
+	cg.automaton = automaton
+
+	// construct action table
+	for i, state := range automaton.states {
+		for k, it := range state.closure {
+			body := idx.bodies[it.prod]
+			if it.dot < len(body) {
+				if sym := body[it.dot]; idx.isTerminal(sym) {
+					cg.actionTable.set(i, idx.symbols[sym], newShiftAction(automaton.transitions[i][sym]))
+				}
+				continue
+			}
+			prod := idx.products[it.prod]
+			for _, term := range state.closureLAs[k].members() {
+				if prod.Head != augStartElem {
+					cg.actionTable.set(i, idx.symbols[term], newReduceAction(prod))
+				} else if idx.symbols[term] == eot {
+					cg.actionTable.set(i, eot, newAcceptAction())
+				}
+			}
+		}
+	}
+
+	cg.actionTable.resolve(cg.augG)
+
+	// construct goto table
+	for i := range automaton.states {
+		for sym, j := range automaton.transitions[i] {
+			if !idx.isTerminal(sym) {
+				cg.gotoTable.set(i, idx.symbols[sym], j)
+			}
+		}
+	}
+
+	cg.describeConflicts()
+	cg.findMergeInducedConflicts()
+	cg.explainConflicts()
+
+	// dumpActionTable(cg.actionTable)
+	// dumpGoToTable(cg.gotoTable)
+}
@@ -0,0 +1,159 @@
+package gdpgen
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+var modes = []TableMode{CANONICAL_LR1, LALR1, PAGER_LR1}
+
+func TestCompileModes(t *testing.T) {
+	g := newCalcGrammar(t)
+	for _, mode := range modes {
+		cg, err := Compile(g, WithTableMode(mode), Strict())
+		if err != nil {
+			t.Errorf("%v: %v", mode, err)
+			continue
+		}
+		if cg.Mode() != mode {
+			t.Errorf("%v: Mode() = %v", mode, cg.Mode())
+		}
+		parser := cg.NewParser(g.NewLexer())
+		for input, want := range map[string]int{"1 + 2 * 3": 7, "(1 + 2) * 3": 9, "8 / 2 / 2": 2} {
+			if value, err := parser.Parse(input); err != nil || value != want {
+				t.Errorf("%v: Parse(%q) = %v, %v, want %v", mode, input, value, err, want)
+			}
+		}
+		if _, err := parser.Parse("1 + * 2"); err == nil {
+			t.Errorf("%v: Parse(%q) succeeded", mode, "1 + * 2")
+		}
+	}
+}
+
+func TestCompileDoesNotModifyGrammar(t *testing.T) {
+	g := newCalcGrammar(t)
+	products := append([]*Product{}, g.Products...)
+	index := g.index
+	if _, err := Compile(g); err != nil {
+		t.Fatal(err)
+	}
+	if g.index != index {
+		t.Errorf("Compile changed the analysis cached in g")
+	}
+	if len(g.Products) != len(products) {
+		t.Fatalf("Compile added %v products to g", len(g.Products)-len(products))
+	}
+	for i, p := range g.Products {
+		if p != products[i] || p.Head == augStartElem {
+			t.Errorf("Compile modified product %v of g", i)
+		}
+	}
+}
+
+func TestCompileConcurrently(t *testing.T) {
+	g := newCalcGrammar(t)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := Compile(g); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// sentences returns every string of at most n words over terms.
+func sentences(terms []string, n int) []string {
+	all := []string{""}
+	last := []string{""}
+	for length := 1; length <= n; length++ {
+		next := []string{}
+		for _, prefix := range last {
+			for _, term := range terms {
+				next = append(next, strings.TrimSpace(prefix+" "+term))
+			}
+		}
+		all = append(all, next...)
+		last = next
+	}
+	return all
+}
+
+func TestPagerAcceptsCanonicalLanguage(t *testing.T) {
+	tests := []struct {
+		name  string
+		src   string
+		terms []string
+		// lalrRR is the number of reduce/reduce conflicts LALR1 adds
+		lalrRR int
+	}{
+		{
+			"not LALR",
+			`s : "a" x "d" | "b" y "d" | "a" y "e" | "b" x "e" ; x : "c" ; y : "c" ;`,
+			[]string{"a", "b", "c", "d", "e"},
+			2,
+		},
+		{
+			"nested lists",
+			`s : "(" l ")" | "x" ; l : s | l "," s | %empty ;`,
+			[]string{"(", ")", ",", "x"},
+			0,
+		},
+		{
+			"not LALR with context",
+			`s : "a" p "c" | "a" q "d" | "b" q "c" | "b" p "d" | "z" p ; p : "e" ; q : "e" ;`,
+			[]string{"a", "b", "c", "d", "e", "z"},
+			2,
+		},
+	}
+	for _, tc := range tests {
+		g, err := ParseGrammar(strings.NewReader(tc.src))
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		canonical, err := Compile(g, WithTableMode(CANONICAL_LR1), Strict())
+		if err != nil {
+			t.Errorf("%v: canonical: %v", tc.name, err)
+			continue
+		}
+		pager, err := Compile(g, WithTableMode(PAGER_LR1), Strict())
+		if err != nil {
+			t.Errorf("%v: Pager: %v", tc.name, err)
+			continue
+		}
+		lalr, err := Compile(g, WithTableMode(LALR1), ExpectConflicts(0, tc.lalrRR))
+		if err != nil {
+			t.Errorf("%v: LALR: %v", tc.name, err)
+			continue
+		}
+		for _, c := range lalr.Conflicts() {
+			if !c.MergeInduced {
+				t.Errorf("%v: LALR conflict %v is not merge-induced", tc.name, c)
+			}
+		}
+		if !(lalr.numStates() <= pager.numStates() && pager.numStates() <= canonical.numStates()) {
+			t.Errorf("%v: %v LALR, %v Pager and %v canonical states", tc.name, lalr.numStates(), pager.numStates(), canonical.numStates())
+		}
+
+		want, got := canonical.NewParser(g.NewLexer()), pager.NewParser(g.NewLexer())
+		accepted := 0
+		for _, input := range sentences(tc.terms, 5) {
+			_, wantErr := want.Parse(input)
+			_, gotErr := got.Parse(input)
+			if (wantErr == nil) != (gotErr == nil) {
+				t.Errorf("%v: Parse(%q): canonical %v, Pager %v", tc.name, input, wantErr, gotErr)
+			}
+			if wantErr == nil {
+				accepted++
+			}
+		}
+		if accepted == 0 {
+			t.Errorf("%v: no sentence accepted", tc.name)
+		}
+	}
+}
@@ -0,0 +1,340 @@
+package gdpgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	SHIFT_REDUCE ConflictKind = iota
+	REDUCE_REDUCE
+)
+
+// ConflictKind tells which actions competed for a parsing table cell.
+type ConflictKind int
+
+func (k ConflictKind) String() string {
+	switch k {
+	case SHIFT_REDUCE:
+		return "shift/reduce"
+	case REDUCE_REDUCE:
+		return "reduce/reduce"
+	}
+	return "unknown"
+}
+
+const (
+	RESOLVED_SHIFT Resolution = iota
+	RESOLVED_REDUCE
+	RESOLVED_ERROR
+)
+
+// Resolution is the action that was put in the table for a conflict.
+type Resolution int
+
+func (r Resolution) String() string {
+	switch r {
+	case RESOLVED_SHIFT:
+		return "shift"
+	case RESOLVED_REDUCE:
+		return "reduce"
+	case RESOLVED_ERROR:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Item is an LR(1) item [Product.Head -> α · β, Lookahead] where Dot is the
+// length of α.
+type Item struct {
+	Product   *Product
+	Dot       int
+	Lookahead *ProductElem
+}
+
+func (it Item) String() string {
+	var b strings.Builder
+	b.WriteString(it.Product.Head.Sig)
+	b.WriteString(" ->")
+	for i, elem := range it.Product.Body {
+		if i == it.Dot {
+			b.WriteString(" •")
+		}
+		if elem != EmptyElem {
+			b.WriteString(" " + elem.Sig)
+		}
+	}
+	if it.Dot >= len(it.Product.Body) {
+		b.WriteString(" •")
+	}
+	if it.Lookahead != nil {
+		b.WriteString(", " + it.Lookahead.Sig)
+	}
+	return b.String()
+}
+
+// Conflict describes a parsing table cell for which more than one action
+// was possible, and how it was decided.
+type Conflict struct {
+	Kind      ConflictKind
+	State     int
+	Lookahead *ProductElem
+
+	// ShiftTo is the state shifted to by the competing shift, or -1.
+	ShiftTo int
+	// Products are the competing reductions, in declaration order.
+	Products []*Product
+	// Items are the items of State that produce the competing actions.
+	Items []Item
+
+	Resolution Resolution
+	// Chosen is the product reduced by when Resolution is RESOLVED_REDUCE.
+	Chosen *Product
+	// ByPrecedence is true if precedence declarations decided the conflict.
+	// Such conflicts are intended and do not count against %expect.
+	ByPrecedence bool
+	Reason       string
+
+	// MergeInduced is true for a reduce/reduce conflict that canonical LR(1)
+	// does not have and that only comes from merging states with the same
+	// core (see LALR1).
+	MergeInduced bool
+
+	// Counterexample shows an input that reaches the conflict.
+	Counterexample *Counterexample
+}
+
+func (c *Conflict) String() string {
+	var action string
+	switch c.Resolution {
+	case RESOLVED_SHIFT:
+		action = fmt.Sprintf("shift to state %v", c.ShiftTo)
+	case RESOLVED_REDUCE:
+		action = fmt.Sprintf("reduce by %v", c.Chosen)
+	default:
+		action = "syntax error"
+	}
+	items := make([]string, len(c.Items))
+	for i, it := range c.Items {
+		items[i] = "[" + it.String() + "]"
+	}
+	return fmt.Sprintf("state %v: %v conflict on %v between %v, resolved as %v (%v)",
+		c.State, c.Kind, c.Lookahead.Sig, strings.Join(items, " and "), action, c.Reason)
+}
+
+// ConflictError is returned when the conflicts of a grammar do not match
+// the expected counts.
+type ConflictError struct {
+	Conflicts    []*Conflict
+	ShiftReduce  int
+	ReduceReduce int
+	ExpectedSR   int
+	ExpectedRR   int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("grammar has %v shift/reduce and %v reduce/reduce conflicts, expected %v and %v",
+		e.ShiftReduce, e.ReduceReduce, e.ExpectedSR, e.ExpectedRR)
+}
+
+// Option configures NewParserWithOptions.
+type Option func(*parserConfig)
+
+type parserConfig struct {
+	strict    bool
+	expectSR  int
+	expectRR  int
+	hasExpect bool
+	mode      TableMode
+}
+
+const (
+	CANONICAL_LR1 TableMode = iota
+	LALR1
+	PAGER_LR1
+)
+
+// TableMode selects how the LR automaton is constructed.
+//
+// CANONICAL_LR1 builds the full canonical LR(1) collection. LALR1 merges
+// states with identical cores, which gives far fewer states but may add
+// reduce/reduce conflicts; those are reported with MergeInduced set.
+// PAGER_LR1 only merges states with the same core that are weakly
+// compatible in Pager's sense, which yields LALR-sized tables for most
+// grammars without LALR's extra conflicts, and accepts the same language as
+// CANONICAL_LR1.
+type TableMode int
+
+func (m TableMode) String() string {
+	switch m {
+	case CANONICAL_LR1:
+		return "canonical LR(1)"
+	case LALR1:
+		return "LALR(1)"
+	case PAGER_LR1:
+		return "minimal LR(1) (Pager)"
+	}
+	return "unknown"
+}
+
+// WithTableMode selects the construction of the LR automaton.
+func WithTableMode(mode TableMode) Option {
+	return func(c *parserConfig) {
+		c.mode = mode
+	}
+}
+
+// Strict makes construction fail unless the number of unresolved conflicts
+// matches the expected counts, which default to zero.
+func Strict() Option {
+	return func(c *parserConfig) {
+		c.strict = true
+	}
+}
+
+// ExpectConflicts sets the expected number of shift/reduce and
+// reduce/reduce conflicts, overriding %expect and %expect-rr. A mismatch
+// makes construction fail.
+func ExpectConflicts(sr, rr int) Option {
+	return func(c *parserConfig) {
+		c.expectSR, c.expectRR, c.hasExpect = sr, rr, true
+	}
+}
+
+// Expect declares the number of shift/reduce (%expect) and reduce/reduce
+// (%expect-rr) conflicts the grammar is known to have.
+func (g *G) Expect(sr, rr int) {
+	g.expect = &[2]int{sr, rr}
+}
+
+// NewParserWithOptions builds a parser like NewParser, but returns an error
+// instead of panicking or logging: a ValidationError if g has errors, or a
+// ConflictError when the conflicts of g do not match the expectations set
+// by options or by G.Expect.
+func NewParserWithOptions(g *G, lex Lexer, opts ...Option) (*Parser, error) {
+	cg, err := Compile(g, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return cg.NewParser(lex), nil
+}
+
+// Conflicts returns every conflict met while building the parsing table,
+// including the ones resolved by precedence, ordered by state.
+func (cg *CompiledGrammar) Conflicts() []*Conflict {
+	return cg.actionTable.conflicts
+}
+
+// countConflicts counts the conflicts that were not resolved by precedence.
+func countConflicts(conflicts []*Conflict) (sr, rr int) {
+	for _, c := range conflicts {
+		if c.ByPrecedence {
+			continue
+		}
+		if c.Kind == SHIFT_REDUCE {
+			sr++
+		} else {
+			rr++
+		}
+	}
+	return sr, rr
+}
+
+// describeConflicts fills in the items of each conflict from the state they
+// occurred in.
+func (cg *CompiledGrammar) describeConflicts() {
+	idx := cg.automaton.idx
+	for _, c := range cg.actionTable.conflicts {
+		c.Items = []Item{}
+		term := idx.ids[c.Lookahead]
+		state := cg.automaton.states[c.State]
+		for k, it := range state.closure {
+			body := idx.bodies[it.prod]
+			if it.dot < len(body) {
+				if c.Kind == SHIFT_REDUCE && body[it.dot] == term {
+					// the lookahead of a shift item does not matter
+					c.Items = append(c.Items, Item{idx.products[it.prod], it.dot, nil})
+				}
+				continue
+			}
+			for _, p := range c.Products {
+				if p == idx.products[it.prod] && state.closureLAs[k].has(term) {
+					c.Items = append(c.Items, Item{p, it.dot, c.Lookahead})
+				}
+			}
+		}
+	}
+}
+
+// findMergeInducedConflicts marks the reduce/reduce conflicts of a merged
+// automaton that no canonical LR(1) state with the same core has. The
+// canonical automaton is only built if there is such a conflict to check.
+func (cg *CompiledGrammar) findMergeInducedConflicts() {
+	if cg.mode == CANONICAL_LR1 {
+		return
+	}
+	idx := cg.automaton.idx
+	var canonical *lrAutomaton
+	for _, c := range cg.actionTable.conflicts {
+		if c.Kind != REDUCE_REDUCE {
+			continue
+		}
+		if canonical == nil {
+			canonical = buildAutomaton(idx, CANONICAL_LR1)
+		}
+		core := coreKey(cg.automaton.states[c.State].kernel)
+		term := idx.ids[c.Lookahead]
+		c.MergeInduced = true
+		for _, state := range canonical.states {
+			if coreKey(state.kernel) != core {
+				continue
+			}
+			reducible := 0
+			for k, it := range state.closure {
+				if it.dot == len(idx.bodies[it.prod]) && state.closureLAs[k].has(term) {
+					for _, p := range c.Products {
+						if p == idx.products[it.prod] {
+							reducible++
+						}
+					}
+				}
+			}
+			if reducible > 1 {
+				c.MergeInduced = false
+				break
+			}
+		}
+		if c.MergeInduced {
+			c.Reason += ", introduced by merging states"
+		}
+	}
+}
+
+func hasItem(items []Item, test Item) bool {
+	for _, it := range items {
+		if it == test {
+			return true
+		}
+	}
+	return false
+}
+
+func sortProducts(g *G, products []*Product) {
+	sort.SliceStable(products, func(i, j int) bool {
+		return productIndex(g, products[i]) < productIndex(g, products[j])
+	})
+}
+
+func sortConflicts(conflicts []*Conflict) {
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		a, b := conflicts[i], conflicts[j]
+		if a.State != b.State {
+			return a.State < b.State
+		}
+		if a.Lookahead.Sig != b.Lookahead.Sig {
+			return a.Lookahead.Sig < b.Lookahead.Sig
+		}
+		return a.Kind < b.Kind
+	})
+}
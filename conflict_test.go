@@ -0,0 +1,87 @@
+package gdpgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExpectAndStrict(t *testing.T) {
+	const (
+		shiftReduce  = "%token num /\\d+/\ne : e \"+\" e | num ;"
+		reduceReduce = "s : a | b ; a : \"x\" ; b : \"x\" ;"
+	)
+	tests := []struct {
+		name   string
+		src    string
+		opts   []Option
+		sr, rr int
+		ok     bool
+	}{
+		{"default", shiftReduce, nil, 1, 0, true},
+		{"strict", shiftReduce, []Option{Strict()}, 1, 0, false},
+		{"expected", shiftReduce, []Option{ExpectConflicts(1, 0)}, 1, 0, true},
+		{"expected too few", shiftReduce, []Option{ExpectConflicts(0, 0)}, 1, 0, false},
+		{"%expect", "%expect 1\n" + shiftReduce, nil, 1, 0, true},
+		{"%expect mismatch", "%expect 2\n" + shiftReduce, nil, 1, 0, false},
+		{"option overrides %expect", "%expect 2\n" + shiftReduce, []Option{ExpectConflicts(1, 0)}, 1, 0, true},
+		{"reduce/reduce", reduceReduce, nil, 0, 1, true},
+		{"reduce/reduce strict", reduceReduce, []Option{Strict()}, 0, 1, false},
+		{"%expect-rr", "%expect-rr 1\n" + reduceReduce, []Option{Strict()}, 0, 1, true},
+		{"precedence", calcGrammar, []Option{Strict()}, 0, 0, true},
+	}
+	for _, tc := range tests {
+		g, err := ParseGrammar(strings.NewReader(tc.src))
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		cg, err := Compile(g, tc.opts...)
+		if tc.ok {
+			if err != nil {
+				t.Errorf("%v: %v", tc.name, err)
+				continue
+			}
+			if sr, rr := countConflicts(cg.Conflicts()); sr != tc.sr || rr != tc.rr {
+				t.Errorf("%v: %v shift/reduce and %v reduce/reduce conflicts, want %v and %v", tc.name, sr, rr, tc.sr, tc.rr)
+			}
+			continue
+		}
+		ce, ok := err.(*ConflictError)
+		if !ok {
+			t.Errorf("%v: Compile = %v, want a *ConflictError", tc.name, err)
+			continue
+		}
+		if ce.ShiftReduce != tc.sr || ce.ReduceReduce != tc.rr || len(ce.Conflicts) != tc.sr+tc.rr {
+			t.Errorf("%v: %v", tc.name, ce)
+		}
+	}
+}
+
+func TestConflictDetails(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader("%token num /\\d+/\ne : e \"+\" e | num ;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conflicts := cg.Conflicts()
+	if len(conflicts) != 1 {
+		t.Fatalf("%v conflicts, want 1", len(conflicts))
+	}
+	c := conflicts[0]
+	if c.Kind != SHIFT_REDUCE || c.Lookahead.Sig != "+" || c.Resolution != RESOLVED_SHIFT || c.ByPrecedence {
+		t.Errorf("conflict %v", c)
+	}
+	if c.ShiftTo < 0 || len(c.Products) != 1 || productRule(c.Products[0]) != "e -> e + e" || len(c.Items) != 2 {
+		t.Errorf("conflict %v: shift to %v, products %v, items %v", c, c.ShiftTo, c.Products, c.Items)
+	}
+	if c.Counterexample == nil || c.Counterexample.Example != "num + num • +" {
+		t.Fatalf("counterexample %v", c.Counterexample)
+	}
+	contexts := strings.Join(c.Counterexample.Contexts, "; ")
+	if contexts != "e(e + e •) +; e + e(e • + e)" && contexts != "e + e(e • + e); e(e + e •) +" {
+		t.Errorf("contexts %v", contexts)
+	}
+}
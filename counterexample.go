@@ -0,0 +1,147 @@
+package gdpgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Counterexample shows how the parser can get into a conflicting state.
+type Counterexample struct {
+	// Prefix is a shortest sentential form whose symbols lead from the start
+	// state to the conflict state.
+	Prefix []*ProductElem
+	// Example is Prefix with every non-terminal expanded to a shortest
+	// terminal string, followed by • and the conflict lookahead.
+	Example string
+	// Contexts holds, for every competing item, Prefix with the item's
+	// product spelled out around the dot, e.g.
+	//
+	//	if e then s(if e then s • else s)
+	//	if e then s(if e then s •) else
+	//
+	// They are the partial parses the items stand for after Prefix, not
+	// derivations of one sentence: the text after the dot differs between
+	// them.
+	Contexts []string
+}
+
+func (c *Counterexample) String() string {
+	return fmt.Sprintf("example: %v\n  %v", c.Example, strings.Join(c.Contexts, "\n  "))
+}
+
+// explainConflicts attaches a counterexample to every conflict, computed
+// from the transitions of the LR automaton.
+func (cg *CompiledGrammar) explainConflicts() {
+	if len(cg.actionTable.conflicts) == 0 {
+		return
+	}
+	paths := cg.shortestPaths()
+	yields := shortestYields(cg.augG)
+	for _, c := range cg.actionTable.conflicts {
+		prefix, ok := paths[c.State]
+		if !ok {
+			continue
+		}
+		example := []string{}
+		for _, sym := range prefix {
+			for _, term := range yields[sym] {
+				example = append(example, term.Sig)
+			}
+		}
+		example = append(example, "•", c.Lookahead.Sig)
+
+		contexts := []string{}
+		for _, it := range c.Items {
+			contexts = append(contexts, itemContext(prefix, it, c.Lookahead))
+		}
+		c.Counterexample = &Counterexample{prefix, strings.Join(example, " "), contexts}
+	}
+}
+
+// itemContext spells out it within prefix. The last it.Dot symbols of any
+// path into a state are the symbols before the dot of its kernel items, so
+// the product starts right after prefix[:len(prefix)-it.Dot].
+func itemContext(prefix []*ProductElem, it Item, lookahead *ProductElem) string {
+	words := []string{}
+	for _, sym := range prefix[:len(prefix)-it.Dot] {
+		words = append(words, sym.Sig)
+	}
+	inner := []string{}
+	for i, elem := range it.Product.Body {
+		if i == it.Dot {
+			inner = append(inner, "•")
+		}
+		if elem != EmptyElem {
+			inner = append(inner, elem.Sig)
+		}
+	}
+	if it.Dot >= len(it.Product.Body) {
+		inner = append(inner, "•")
+	}
+	words = append(words, it.Product.Head.Sig+"("+strings.Join(inner, " ")+")")
+	if it.Dot >= len(it.Product.Body) || it.Product.Body[it.Dot] == EmptyElem {
+		words = append(words, lookahead.Sig)
+	}
+	return strings.Join(words, " ")
+}
+
+// shortestPaths returns, for every state, a shortest sequence of symbols
+// leading to it from state 0.
+func (cg *CompiledGrammar) shortestPaths() map[int][]*ProductElem {
+	automaton := cg.automaton
+	paths := map[int][]*ProductElem{0: {}}
+	queue := []int{0}
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+		for _, sym := range sortedKeys(automaton.transitions[state]) {
+			to := automaton.transitions[state][sym]
+			if _, seen := paths[to]; seen {
+				continue
+			}
+			path := make([]*ProductElem, len(paths[state])+1)
+			copy(path, paths[state])
+			path[len(path)-1] = automaton.idx.symbols[sym]
+			paths[to] = path
+			queue = append(queue, to)
+		}
+	}
+	return paths
+}
+
+// shortestYields returns a shortest terminal string derivable from every
+// symbol of g. Symbols that derive no terminal string are missing.
+func shortestYields(g *G) map[*ProductElem][]*ProductElem {
+	yields := make(map[*ProductElem][]*ProductElem)
+	for _, sym := range g.GetSymbolSet() {
+		if sym == EmptyElem {
+			yields[sym] = []*ProductElem{}
+		} else if sym.IsTerminal {
+			yields[sym] = []*ProductElem{sym}
+		}
+	}
+	changed := true
+	for changed {
+		changed = false
+		for _, product := range g.Products {
+			yield := []*ProductElem{}
+			complete := true
+			for _, elem := range product.Body {
+				y, ok := yields[elem]
+				if !ok {
+					complete = false
+					break
+				}
+				yield = append(yield, y...)
+			}
+			if !complete {
+				continue
+			}
+			if old, ok := yields[product.Head]; !ok || len(yield) < len(old) {
+				yields[product.Head] = yield
+				changed = true
+			}
+		}
+	}
+	return yields
+}
@@ -0,0 +1,78 @@
+package gdpgen
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// WriteDot writes the LR automaton of cg as a Graphviz DOT graph. Every
+// node lists the items of its state, kernel items first, with their
+// lookaheads; edges are labeled with the symbol shifted or gone to, gotos
+// being dashed. States with a conflict are filled: red if the conflict was
+// left unresolved, yellow if precedence decided it. Tables loaded with
+// LoadCompiledGrammar have no automaton and cannot be written.
+func (cg *CompiledGrammar) WriteDot(w io.Writer) error {
+	if cg.automaton == nil {
+		return errors.New(fmt.Sprintf("the LR automaton of loaded tables is not available"))
+	}
+	idx := cg.automaton.idx
+
+	unresolved := make(map[int]bool)
+	resolved := make(map[int]bool)
+	for _, c := range cg.Conflicts() {
+		if c.ByPrecedence {
+			resolved[c.State] = true
+		} else {
+			unresolved[c.State] = true
+		}
+	}
+
+	b := bufio.NewWriter(w)
+	b.WriteString("digraph automaton {\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for i, state := range cg.automaton.states {
+		lines := []string{fmt.Sprintf("state %v", i)}
+		for k, it := range state.closure {
+			las := []string{}
+			for _, term := range state.closureLAs[k].members() {
+				las = append(las, idx.symbols[term].Sig)
+			}
+			item := Item{idx.products[it.prod], it.dot, nil}
+			lines = append(lines, fmt.Sprintf("%v, %v", item, strings.Join(las, " ")))
+		}
+		attrs := ""
+		switch {
+		case unresolved[i]:
+			attrs = ", style=filled, fillcolor=\"#f4cccc\", color=red"
+		case resolved[i]:
+			attrs = ", style=filled, fillcolor=\"#fff2cc\""
+		}
+		fmt.Fprintf(b, "\t%v [label=\"%v\\l\"%v];\n", i, dotEscape(strings.Join(lines, "\n")), attrs)
+	}
+	for i, transitions := range cg.automaton.transitions {
+		syms := []int{}
+		for sym := range transitions {
+			syms = append(syms, sym)
+		}
+		sort.Ints(syms)
+		for _, sym := range syms {
+			style := ""
+			if !idx.isTerminal(sym) {
+				style = ", style=dashed"
+			}
+			fmt.Fprintf(b, "\t%v -> %v [label=\"%v\"%v];\n", i, transitions[sym], dotEscape(idx.symbols[sym].Sig), style)
+		}
+	}
+	b.WriteString("}\n")
+	return b.Flush()
+}
+
+// dotEscape quotes s for a DOT string, with lines left-justified.
+func dotEscape(s string) string {
+	s = strings.NewReplacer("\\", "\\\\", "\"", "\\\"").Replace(s)
+	return strings.Replace(s, "\n", "\\l", -1)
+}
@@ -0,0 +1,51 @@
+package gdpgen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const wantDot = `digraph automaton {
+	node [shape=box, fontname="monospace"];
+	0 [label="state 0\lS' -> • e, $\le -> • e + e, $ +\le -> • num, $ +\l"];
+	1 [label="state 1\le -> num •, $ +\l"];
+	2 [label="state 2\le -> e • + e, $ +\lS' -> e •, $\l"];
+	3 [label="state 3\le -> e + • e, $ +\le -> • e + e, $ +\le -> • num, $ +\l"];
+	4 [label="state 4\le -> e • + e, $ +\le -> e + e •, $ +\l"%v];
+	0 -> 1 [label="num"];
+	0 -> 2 [label="e", style=dashed];
+	2 -> 3 [label="+"];
+	3 -> 1 [label="num"];
+	3 -> 4 [label="e", style=dashed];
+	4 -> 3 [label="+"];
+}
+`
+
+func TestWriteDot(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		fill string
+	}{
+		{"unresolved", "%token num /\\d+/\ne : e \"+\" e | num ;", `, style=filled, fillcolor="#f4cccc", color=red`},
+		{"precedence", "%token num /\\d+/\n%left \"+\"\ne : e \"+\" e | num ;", `, style=filled, fillcolor="#fff2cc"`},
+	}
+	for _, tc := range tests {
+		g, err := ParseGrammar(strings.NewReader(tc.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cg, err := Compile(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := cg.WriteDot(&b); err != nil {
+			t.Fatal(err)
+		}
+		if want := strings.Replace(wantDot, "%v", tc.fill, 1); b.String() != want {
+			t.Errorf("%v: WriteDot wrote\n%v\nwant\n%v", tc.name, b.String(), want)
+		}
+	}
+}
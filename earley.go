@@ -0,0 +1,256 @@
+package gdpgen
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Backend is implemented by every parser of a G, so that code built around
+// a grammar does not depend on how it is parsed.
+type Backend interface {
+	Parse(w string) (interface{}, error)
+}
+
+// EarleyParser parses with Earley's algorithm, which accepts any context
+// free grammar: it needs no parsing table and so has no conflicts. It uses
+// the Aycock-Horspool treatment of nullable symbols.
+type EarleyParser struct {
+	g   *G
+	lex Lexer
+	idx *grammarIndex
+}
+
+func NewEarleyParser(g *G, lex Lexer) *EarleyParser {
+	return &EarleyParser{g, lex, g.analysis()}
+}
+
+// earleyItem is a dotted product together with the set it was predicted in.
+type earleyItem struct {
+	prod   int
+	dot    int
+	origin int
+}
+
+type earleySet struct {
+	items []earleyItem
+	seen  map[earleyItem]bool
+}
+
+func newEarleySet() *earleySet {
+	return &earleySet{[]earleyItem{}, make(map[earleyItem]bool)}
+}
+
+func (s *earleySet) add(it earleyItem) {
+	if !s.seen[it] {
+		s.seen[it] = true
+		s.items = append(s.items, it)
+	}
+}
+
+type earleyToken struct {
+	token  Token
+	sym    int
+	line   int
+	column int
+}
+
+// Parse parses w and returns the value computed by the Callbacks like
+// Parser.Parse. Where w has several parses, the product declared first is
+// used.
+func (parser *EarleyParser) Parse(w string) (interface{}, error) {
+	forest, err := parser.ParseForest(w)
+	if err != nil {
+		return nil, err
+	}
+	return forest.Evaluate(nil)
+}
+
+// ParseForest parses w and returns all of its parses as a shared packed
+// parse forest, as Parser.ParseForest does.
+func (parser *EarleyParser) ParseForest(w string) (*Forest, error) {
+	tokens, err := parser.tokenize(w)
+	if err != nil {
+		return nil, err
+	}
+	idx := parser.idx
+	n := len(tokens) - 1
+	start, ok := idx.ids[parser.g.StartSymbol]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no products for start symbol %v", parser.g.StartSymbol))
+	}
+
+	chart := []*earleySet{newEarleySet()}
+	for _, p := range idx.prodsOf[start] {
+		chart[0].add(earleyItem{p, 0, 0})
+	}
+	for k := 0; k <= n; k++ {
+		chart = append(chart, newEarleySet())
+		set := chart[k]
+		for i := 0; i < len(set.items); i++ {
+			it := set.items[i]
+			body := idx.bodies[it.prod]
+			if it.dot == len(body) {
+				// complete
+				head := idx.heads[it.prod]
+				for _, waiting := range chart[it.origin].items {
+					wb := idx.bodies[waiting.prod]
+					if waiting.dot < len(wb) && wb[waiting.dot] == head {
+						set.add(earleyItem{waiting.prod, waiting.dot + 1, waiting.origin})
+					}
+				}
+				continue
+			}
+			sym := body[it.dot]
+			if idx.isTerminal(sym) {
+				// scan
+				if k < n && tokens[k].sym == sym {
+					chart[k+1].add(earleyItem{it.prod, it.dot + 1, it.origin})
+				}
+				continue
+			}
+			// predict
+			for _, p := range idx.prodsOf[sym] {
+				set.add(earleyItem{p, 0, k})
+			}
+			if idx.nullable[sym] {
+				set.add(earleyItem{it.prod, it.dot + 1, it.origin})
+			}
+		}
+
+		if k < n && len(chart[k+1].items) == 0 {
+			return nil, parser.syntaxError(set, tokens[k])
+		}
+	}
+
+	accepted := false
+	for _, p := range idx.prodsOf[start] {
+		if chart[n].seen[earleyItem{p, len(idx.bodies[p]), 0}] {
+			accepted = true
+		}
+	}
+	if !accepted {
+		return nil, parser.syntaxError(chart[n], tokens[n])
+	}
+
+	f := &earleyForest{idx, chart, tokens, make(map[forestKey]*ForestNode)}
+	spans := []Span{}
+	for _, token := range tokens {
+		spans = append(spans, token.token.Span)
+	}
+	return &Forest{f.node(start, 0, n), parser.g, spans}, nil
+}
+
+// tokenize reads all tokens of w, the last one being the end of input.
+func (parser *EarleyParser) tokenize(w string) ([]earleyToken, error) {
+	lex := sessionLexer(parser.lex)
+	lex.GetReader(w)
+	terminals := parser.g.GetTerminals()
+	tokens := []earleyToken{}
+	for {
+		token := lex.GetNextToken()
+		line, column := tokenPosition(lex, token)
+		a := getTerminalFrom(terminals, token.Name)
+		sym, ok := parser.idx.ids[a]
+		if a == nil || !ok {
+			return nil, errors.New(fmt.Sprintf("unknown token: %v\n", token))
+		}
+		tokens = append(tokens, earleyToken{token, sym, line, column})
+		if a == eot {
+			return tokens, nil
+		}
+	}
+}
+
+func (parser *EarleyParser) syntaxError(set *earleySet, actual earleyToken) error {
+	idx := parser.idx
+	expected := newBitset(idx.nTerms)
+	for _, it := range set.items {
+		body := idx.bodies[it.prod]
+		if it.dot < len(body) && idx.isTerminal(body[it.dot]) {
+			expected.add(body[it.dot])
+		}
+	}
+	expects := []*ProductElem{}
+	for _, sym := range expected.members() {
+		expects = append(expects, idx.symbols[sym])
+	}
+	return errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. expects one of %v, but actual '%v'\n",
+		actual.line, actual.column, expects, actual.token))
+}
+
+// earleyForest builds the parse forest from a completed chart. A symbol
+// spans [start, end] if the chart holds one of its products completed in
+// set end with origin start; the body is then split from the right, using
+// the items of the chart to find where each symbol starts.
+type earleyForest struct {
+	idx    *grammarIndex
+	chart  []*earleySet
+	tokens []earleyToken
+	nodes  map[forestKey]*ForestNode
+}
+
+func (f *earleyForest) node(sym, start, end int) *ForestNode {
+	symbol := f.idx.symbols[sym]
+	key := forestKey{symbol, start, end}
+	if node, ok := f.nodes[key]; ok {
+		return node
+	}
+	node := &ForestNode{symbol, start, end, nil, []*Derivation{}}
+	f.nodes[key] = node
+	if f.idx.isTerminal(sym) {
+		token := f.tokens[start].token
+		node.Token = &token
+		return node
+	}
+	for _, p := range f.idx.prodsOf[sym] {
+		if !f.chart[end].seen[earleyItem{p, len(f.idx.bodies[p]), start}] {
+			continue
+		}
+		for _, children := range f.splits(p, len(f.idx.bodies[p]), start, end) {
+			node.addAlternative(f.idx.products[p], children)
+		}
+	}
+	return node
+}
+
+// splits returns the ways the symbols before dot in the body of prod span
+// [start, end].
+func (f *earleyForest) splits(prod, dot, start, end int) [][]*ForestNode {
+	if dot == 0 {
+		if start == end {
+			return [][]*ForestNode{{}}
+		}
+		return nil
+	}
+	sym := f.idx.bodies[prod][dot-1]
+	mids := []int{}
+	if f.idx.isTerminal(sym) {
+		if start < end && f.tokens[end-1].sym == sym {
+			mids = append(mids, end-1)
+		}
+	} else {
+		for mid := start; mid <= end; mid++ {
+			for _, p := range f.idx.prodsOf[sym] {
+				if f.chart[end].seen[earleyItem{p, len(f.idx.bodies[p]), mid}] {
+					mids = append(mids, mid)
+					break
+				}
+			}
+		}
+	}
+
+	splits := [][]*ForestNode{}
+	for _, mid := range mids {
+		if !f.chart[mid].seen[earleyItem{prod, dot - 1, start}] {
+			continue
+		}
+		child := f.node(sym, mid, end)
+		for _, prefix := range f.splits(prod, dot-1, start, mid) {
+			children := make([]*ForestNode, len(prefix)+1)
+			copy(children, prefix)
+			children[len(prefix)] = child
+			splits = append(splits, children)
+		}
+	}
+	return splits
+}
@@ -0,0 +1,157 @@
+package gdpgen
+
+import (
+	"strings"
+)
+
+// The EBNF helpers below desugar optional, repeated, grouped and separated
+// symbols into ordinary products on synthesized non-terminals. The same
+// construct is only synthesized once per grammar, so calling g.Many(x)
+// twice returns the same symbol. Semantic values arrive in callbacks as:
+//
+//	Optional(x)       the value of x, or nil
+//	Many, Many1       []interface{} with one value per x
+//	SepBy, SepBy1     []interface{} with the values of x, separators dropped
+//	Group(alts...)    the value of a single-symbol alternative, nil for an
+//	                  empty one, []interface{} of the values otherwise
+
+// Optional returns a symbol matching x or nothing (x?).
+func (g *G) Optional(x *ProductElem) *ProductElem {
+	name := symbolName(x) + "?"
+	return g.synthesize(name, func(opt *ProductElem) {
+		g.AddProduct(&Product{opt, []*ProductElem{EmptyElem},
+			func(values []interface{}) interface{} {
+				return nil
+			}})
+		g.AddProduct(&Product{opt, []*ProductElem{x},
+			func(values []interface{}) interface{} {
+				return values[0]
+			}})
+	})
+}
+
+// Many returns a symbol matching zero or more x (x*).
+func (g *G) Many(x *ProductElem) *ProductElem {
+	name := symbolName(x) + "*"
+	return g.synthesize(name, func(list *ProductElem) {
+		g.AddProduct(&Product{list, []*ProductElem{EmptyElem},
+			func(values []interface{}) interface{} {
+				return []interface{}{}
+			}})
+		g.AddProduct(&Product{list, []*ProductElem{list, x},
+			func(values []interface{}) interface{} {
+				return append(values[0].([]interface{}), values[1])
+			}})
+	})
+}
+
+// Many1 returns a symbol matching one or more x (x+).
+func (g *G) Many1(x *ProductElem) *ProductElem {
+	name := symbolName(x) + "+"
+	return g.synthesize(name, func(list *ProductElem) {
+		g.AddProduct(&Product{list, []*ProductElem{x},
+			func(values []interface{}) interface{} {
+				return []interface{}{values[0]}
+			}})
+		g.AddProduct(&Product{list, []*ProductElem{list, x},
+			func(values []interface{}) interface{} {
+				return append(values[0].([]interface{}), values[1])
+			}})
+	})
+}
+
+// SepBy1 returns a symbol matching one or more x separated by sep (x ++ sep).
+func (g *G) SepBy1(x, sep *ProductElem) *ProductElem {
+	name := symbolName(x) + " ++ " + symbolName(sep)
+	return g.synthesize(name, func(list *ProductElem) {
+		g.AddProduct(&Product{list, []*ProductElem{x},
+			func(values []interface{}) interface{} {
+				return []interface{}{values[0]}
+			}})
+		g.AddProduct(&Product{list, []*ProductElem{list, sep, x},
+			func(values []interface{}) interface{} {
+				return append(values[0].([]interface{}), values[2])
+			}})
+	})
+}
+
+// SepBy returns a symbol matching zero or more x separated by sep (x ** sep).
+func (g *G) SepBy(x, sep *ProductElem) *ProductElem {
+	name := symbolName(x) + " ** " + symbolName(sep)
+	return g.synthesize(name, func(list *ProductElem) {
+		nonEmpty := g.SepBy1(x, sep)
+		g.AddProduct(&Product{list, []*ProductElem{EmptyElem},
+			func(values []interface{}) interface{} {
+				return []interface{}{}
+			}})
+		g.AddProduct(&Product{list, []*ProductElem{nonEmpty},
+			func(values []interface{}) interface{} {
+				return values[0]
+			}})
+	})
+}
+
+// Group returns a symbol matching any of the alternatives ((a b | c)).
+func (g *G) Group(alts ...[]*ProductElem) *ProductElem {
+	names := []string{}
+	for _, alt := range alts {
+		names = append(names, sequenceName(alt))
+	}
+	name := "(" + strings.Join(names, " | ") + ")"
+	return g.synthesize(name, func(group *ProductElem) {
+		for _, alt := range alts {
+			switch {
+			case len(alt) == 0 || len(alt) == 1 && alt[0] == EmptyElem:
+				g.AddProduct(&Product{group, []*ProductElem{EmptyElem},
+					func(values []interface{}) interface{} {
+						return nil
+					}})
+			case len(alt) == 1:
+				g.AddProduct(&Product{group, alt,
+					func(values []interface{}) interface{} {
+						return values[0]
+					}})
+			default:
+				g.AddProduct(&Product{group, alt,
+					func(values []interface{}) interface{} {
+						seq := make([]interface{}, len(values))
+						copy(seq, values)
+						return seq
+					}})
+			}
+		}
+	})
+}
+
+// synthesize returns the non-terminal called name, creating it and its
+// products with define the first time it is requested.
+func (g *G) synthesize(name string, define func(*ProductElem)) *ProductElem {
+	if g.synthesized == nil {
+		g.synthesized = make(map[string]*ProductElem)
+	}
+	if elem, ok := g.synthesized[name]; ok {
+		return elem
+	}
+	elem := g.NonTerminal(name)
+	g.synthesized[name] = elem
+	define(elem)
+	return elem
+}
+
+func symbolName(elem *ProductElem) string {
+	if elem == EmptyElem {
+		return "%empty"
+	}
+	return elem.Sig
+}
+
+func sequenceName(seq []*ProductElem) string {
+	if len(seq) == 0 {
+		return "%empty"
+	}
+	names := make([]string, len(seq))
+	for i, elem := range seq {
+		names[i] = symbolName(elem)
+	}
+	return strings.Join(names, " ")
+}
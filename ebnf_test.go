@@ -0,0 +1,69 @@
+package gdpgen
+
+import (
+	"strings"
+	"testing"
+)
+
+// shape renders a semantic value compactly: tokens by their text, lists in
+// brackets.
+func shape(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case Token:
+		return v.Value
+	case []interface{}:
+		parts := make([]string, len(v))
+		for i, elem := range v {
+			parts[i] = shape(elem)
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	}
+	return "?"
+}
+
+func TestEBNFValues(t *testing.T) {
+	tests := []struct {
+		rule  string
+		input string
+		want  string
+	}{
+		{`s : "<" id? ">" ;`, "< >", "[< nil >]"},
+		{`s : "<" id? ">" ;`, "< a >", "[< a >]"},
+		{`s : "<" id* ">" ;`, "< >", "[< [] >]"},
+		{`s : "<" id* ">" ;`, "< a b c >", "[< [a b c] >]"},
+		{`s : "<" id+ ">" ;`, "< a >", "[< [a] >]"},
+		{`s : "<" id+ ">" ;`, "< a b >", "[< [a b] >]"},
+		{`s : "<" (id ** ",") ">" ;`, "< >", "[< [] >]"},
+		{`s : "<" (id ** ",") ">" ;`, "< a , b , c >", "[< [a b c] >]"},
+		{`s : "<" (id ++ ",") ">" ;`, "< a >", "[< [a] >]"},
+		{`s : "<" (id ++ ",") ">" ;`, "< a , b >", "[< [a b] >]"},
+		{`s : "<" ("x" "y" | id | %empty) ">" ;`, "< x y >", "[< [x y] >]"},
+		{`s : "<" ("x" "y" | id | %empty) ">" ;`, "< a >", "[< a >]"},
+		{`s : "<" ("x" "y" | id | %empty) ">" ;`, "< >", "[< nil >]"},
+		{`s : "<" ("," id)* ">" ;`, "< , a , b >", "[< [[, a] [, b]] >]"},
+	}
+	for _, tc := range tests {
+		g, err := ParseGrammar(strings.NewReader("%token id /[a-z]+/\n" + tc.rule))
+		if err != nil {
+			t.Errorf("%v: %v", tc.rule, err)
+			continue
+		}
+		value, err := NewParser(g, g.NewLexer()).Parse(tc.input)
+		if err != nil || shape(value) != tc.want {
+			t.Errorf("%v: Parse(%q) = %v, %v, want %v", tc.rule, tc.input, shape(value), err, tc.want)
+		}
+	}
+}
+
+func TestEBNFSynthesizedOnce(t *testing.T) {
+	x := NewTerminal("x")
+	g := NewGrammar(NewNonTerminal("s"))
+	if g.Many(x) != g.Many(x) || g.SepBy(x, NewTerminal(",")) != g.SepBy(x, NewTerminal(",")) {
+		t.Errorf("the same construct was synthesized twice")
+	}
+	if n := len(g.Products); n != 6 {
+		t.Errorf("%v products, want 6: x* and x ** , with its x ++ ,", n)
+	}
+}
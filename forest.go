@@ -0,0 +1,177 @@
+package gdpgen
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Forest is a shared packed parse forest holding every parse of an input.
+// Nodes are shared between parses: there is one node per symbol and token
+// span, and a node with several Alternatives is a point of ambiguity.
+type Forest struct {
+	Root *ForestNode
+
+	g      *G
+	tokens []Span // spans of the tokens by index, the end of input last
+}
+
+// ForestNode is the symbol Symbol recognized from token Start up to, but
+// not including, token End.
+type ForestNode struct {
+	Symbol *ProductElem
+	Start  int
+	End    int
+	// Token is set for terminal leaves.
+	Token *Token
+	// Alternatives are the ways a non-terminal was derived over the span.
+	Alternatives []*Derivation
+}
+
+// Derivation is one way of deriving a forest node: a product and the nodes
+// of its body symbols, EmptyElem left out.
+type Derivation struct {
+	Product  *Product
+	Children []*ForestNode
+}
+
+func (n *ForestNode) String() string {
+	return fmt.Sprintf("%v[%v:%v]", n.Symbol.Sig, n.Start, n.End)
+}
+
+// Ambiguous reports whether n has more than one derivation.
+func (n *ForestNode) Ambiguous() bool {
+	return len(n.Alternatives) > 1
+}
+
+func (n *ForestNode) addAlternative(prod *Product, children []*ForestNode) {
+	for _, alt := range n.Alternatives {
+		if alt.Product == prod && sameNodes(alt.Children, children) {
+			return
+		}
+	}
+	n.Alternatives = append(n.Alternatives, &Derivation{prod, children})
+}
+
+func sameNodes(a, b []*ForestNode) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Ambiguous reports whether any node reachable from the root has more than
+// one derivation.
+func (f *Forest) Ambiguous() bool {
+	found := false
+	f.walk(func(n *ForestNode) {
+		if n.Ambiguous() {
+			found = true
+		}
+	})
+	return found
+}
+
+// AmbiguousNodes returns the nodes that have more than one derivation.
+func (f *Forest) AmbiguousNodes() []*ForestNode {
+	nodes := []*ForestNode{}
+	f.walk(func(n *ForestNode) {
+		if n.Ambiguous() {
+			nodes = append(nodes, n)
+		}
+	})
+	return nodes
+}
+
+// walk visits every node reachable from the root once, in depth-first order.
+func (f *Forest) walk(visit func(*ForestNode)) {
+	seen := make(map[*ForestNode]bool)
+	var rec func(*ForestNode)
+	rec = func(n *ForestNode) {
+		if seen[n] {
+			return
+		}
+		seen[n] = true
+		visit(n)
+		for _, alt := range n.Alternatives {
+			for _, child := range alt.Children {
+				rec(child)
+			}
+		}
+	}
+	rec(f.Root)
+}
+
+func (f *Forest) String() string {
+	var b strings.Builder
+	f.walk(func(n *ForestNode) {
+		if n.Token != nil {
+			return
+		}
+		for _, alt := range n.Alternatives {
+			fmt.Fprintf(&b, "%v -> %v\n", n, alt.Children)
+		}
+	})
+	return b.String()
+}
+
+// Evaluate runs the semantic Callbacks over one tree of the forest and
+// returns the value of the root. For every ambiguous node, choose returns
+// the index of the alternative to use; if choose is nil the first one is
+// taken. Callbacks of the alternatives that are not chosen never run.
+func (f *Forest) Evaluate(choose func(*ForestNode) int) (interface{}, error) {
+	if choose == nil {
+		choose = func(*ForestNode) int { return 0 }
+	}
+	active := make(map[*ForestNode]bool)
+	var eval func(*ForestNode) (interface{}, error)
+	eval = func(n *ForestNode) (interface{}, error) {
+		if n.Token != nil {
+			return *n.Token, nil
+		}
+		if active[n] {
+			return nil, errors.New(fmt.Sprintf("cyclic derivation of %v", n))
+		}
+		active[n] = true
+		defer delete(active, n)
+
+		i := 0
+		if n.Ambiguous() {
+			i = choose(n)
+			if i < 0 || i >= len(n.Alternatives) {
+				return nil, errors.New(fmt.Sprintf("invalid alternative %v chosen for %v", i, n))
+			}
+		}
+		alt := n.Alternatives[i]
+		values := []interface{}{}
+		children := alt.Children
+		for _, elem := range alt.Product.Body {
+			if elem == EmptyElem {
+				values = append(values, nil)
+				continue
+			}
+			value, err := eval(children[0])
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			children = children[1:]
+		}
+		return reduceValue(f.g, alt.Product, values, f.Span(n)), nil
+	}
+	return eval(f.Root)
+}
+
+// Span returns the part of the input covered by n. An empty node starts and
+// ends where its next token starts.
+func (f *Forest) Span(n *ForestNode) Span {
+	if n.End == n.Start {
+		return emptySpan(f.tokens[n.Start])
+	}
+	return joinSpans(f.tokens[n.Start], f.tokens[n.End-1])
+}
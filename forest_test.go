@@ -0,0 +1,161 @@
+package gdpgen
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const subGrammar = "%token num /\\d+/\ne : e \"-\" e {sub} | num {num} ;"
+
+// newSubGrammar returns an ambiguous grammar of subtractions, so that the
+// grouping chosen shows in the value.
+func newSubGrammar(t *testing.T) *G {
+	g, err := ParseGrammar(strings.NewReader(subGrammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.ProductByLabel("sub").Callback = func(values []interface{}) interface{} {
+		return values[0].(int) - values[2].(int)
+	}
+	g.ProductByLabel("num").Callback = func(values []interface{}) interface{} {
+		n, _ := strconv.Atoi(values[0].(Token).Value)
+		return n
+	}
+	return g
+}
+
+// countTrees returns the number of parse trees below n.
+func countTrees(n *ForestNode) int {
+	if n.Token != nil {
+		return 1
+	}
+	count := 0
+	for _, alt := range n.Alternatives {
+		trees := 1
+		for _, child := range alt.Children {
+			trees *= countTrees(child)
+		}
+		count += trees
+	}
+	return count
+}
+
+// forestParsers returns the GLR and the Earley forest of g by name.
+func forestParsers(t *testing.T, g *G) map[string]func(string) (*Forest, error) {
+	cg, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]func(string) (*Forest, error){
+		"GLR":    cg.NewParser(g.NewLexer()).ParseForest,
+		"Earley": NewEarleyParser(g, g.NewLexer()).ParseForest,
+	}
+}
+
+func TestForestAmbiguity(t *testing.T) {
+	tests := []struct {
+		input string
+		trees int
+		// values are the results of choosing the first and the last
+		// alternative at every ambiguous node, in either order
+		values [2]int
+	}{
+		{"8", 1, [2]int{8, 8}},
+		{"8 - 4", 1, [2]int{4, 4}},
+		{"8 - 4 - 2", 2, [2]int{2, 6}},
+		{"8 - 4 - 2 - 1", 5, [2]int{1, 5}},
+		{"8 - 4 - 2 - 1 - 1", 14, [2]int{0, 6}},
+	}
+	for name, parse := range forestParsers(t, newSubGrammar(t)) {
+		for _, tc := range tests {
+			forest, err := parse(tc.input)
+			if err != nil {
+				t.Errorf("%v: ParseForest(%q): %v", name, tc.input, err)
+				continue
+			}
+			if n := countTrees(forest.Root); n != tc.trees || forest.Ambiguous() != (tc.trees > 1) {
+				t.Errorf("%v: ParseForest(%q) has %v trees, ambiguous %v, want %v", name, tc.input, n, forest.Ambiguous(), tc.trees)
+			}
+			if span := forest.Span(forest.Root); span.Start != 0 || span.End != len(tc.input) {
+				t.Errorf("%v: ParseForest(%q) spans %v", name, tc.input, span)
+			}
+			first, err := forest.Evaluate(nil)
+			if err != nil {
+				t.Errorf("%v: %q: %v", name, tc.input, err)
+				continue
+			}
+			last, err := forest.Evaluate(func(n *ForestNode) int { return len(n.Alternatives) - 1 })
+			if err != nil {
+				t.Errorf("%v: %q: %v", name, tc.input, err)
+				continue
+			}
+			if values := [2]int{first.(int), last.(int)}; values != tc.values && values != [2]int{tc.values[1], tc.values[0]} {
+				t.Errorf("%v: %q: first and last alternatives give %v, want %v", name, tc.input, values, tc.values)
+			}
+		}
+		if _, err := parse("8 - - 4"); err == nil {
+			t.Errorf("%v: ParseForest(%q) succeeded", name, "8 - - 4")
+		}
+	}
+}
+
+func TestForestNonDeterministic(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(`s : "a" s "a" | "b" s "b" | %empty ;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"", true},
+		{"a a", true},
+		{"a b b a", true},
+		{"b a a a a b", true},
+		{"a b a", false},
+		{"a b", false},
+	}
+	for name, parse := range forestParsers(t, g) {
+		for _, tc := range tests {
+			forest, err := parse(tc.input)
+			if (err == nil) != tc.ok {
+				t.Errorf("%v: ParseForest(%q) = %v, want ok %v", name, tc.input, err, tc.ok)
+				continue
+			}
+			if err == nil && forest.Ambiguous() {
+				t.Errorf("%v: ParseForest(%q) is ambiguous:\n%v", name, tc.input, forest)
+			}
+		}
+	}
+}
+
+func TestForestNullable(t *testing.T) {
+	tests := []struct {
+		src   string
+		input string
+	}{
+		{`s : a a a | "x" | "x" s ; a : "x" a | %empty ;`, "x x"},
+		{`s : b a | %empty ; a : "y" "y" s | %empty ; b : "y" s a | "x" b b | "x" ;`, "y y x y y x"},
+		{`s : a a "x" | a "x" a | a ; a : "x" | %empty ;`, "x x"},
+		{`e : e e | "x" e | "x" ;`, "x x x x"},
+	}
+	for _, tc := range tests {
+		g, err := ParseGrammar(strings.NewReader(tc.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees := make(map[string]int)
+		for name, parse := range forestParsers(t, g) {
+			forest, err := parse(tc.input)
+			if err != nil {
+				t.Errorf("%v: %v: ParseForest(%q): %v", tc.src, name, tc.input, err)
+				continue
+			}
+			trees[name] = countTrees(forest.Root)
+		}
+		if trees["GLR"] != trees["Earley"] {
+			t.Errorf("%v: ParseForest(%q) has %v trees with GLR and %v with Earley", tc.src, tc.input, trees["GLR"], trees["Earley"])
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package gdpgen
+
+import (
+	"errors"
+	"fmt"
+)
+
+// gssNode is a node of the graph-structured stack: an LR state reached
+// after the first level tokens. Its edges lead to the nodes below it, each
+// labelled with the forest node of the symbol in between.
+type gssNode struct {
+	state int
+	level int
+	edges []*gssEdge
+}
+
+type gssEdge struct {
+	to   *gssNode
+	node *ForestNode
+}
+
+func (n *gssNode) addEdge(to *gssNode, node *ForestNode) bool {
+	for _, e := range n.edges {
+		if e.to == to && e.node == node {
+			return false
+		}
+	}
+	n.edges = append(n.edges, &gssEdge{to, node})
+	return true
+}
+
+type gssPath struct {
+	to       *gssNode
+	children []*ForestNode
+}
+
+// paths returns every path of length n going down from node, with the
+// forest nodes along it in input order.
+func (n *gssNode) paths(length int) []gssPath {
+	if length == 0 {
+		return []gssPath{{n, []*ForestNode{}}}
+	}
+	paths := []gssPath{}
+	for _, e := range n.edges {
+		for _, p := range e.to.paths(length - 1) {
+			children := make([]*ForestNode, len(p.children)+1)
+			copy(children, p.children)
+			children[len(children)-1] = e.node
+			paths = append(paths, gssPath{p.to, children})
+		}
+	}
+	return paths
+}
+
+type forestKey struct {
+	symbol     *ProductElem
+	start, end int
+}
+
+// glr holds the state of one ParseForest run.
+type glr struct {
+	parser *Parser
+	nodes  map[forestKey]*ForestNode
+}
+
+func (r *glr) forestNode(symbol *ProductElem, start, end int) *ForestNode {
+	key := forestKey{symbol, start, end}
+	node, ok := r.nodes[key]
+	if !ok {
+		node = &ForestNode{symbol, start, end, nil, []*Derivation{}}
+		r.nodes[key] = node
+	}
+	return node
+}
+
+type glrShift struct {
+	from  *gssNode
+	state int
+}
+
+// ParseForest parses w with a GLR driver over the parsing table. Where the
+// table has a conflict that precedence did not decide, every action is
+// followed on a graph-structured stack, so all parses of w are returned as
+// a shared packed parse forest. No Callback runs until Forest.Evaluate is
+// called.
+func (parser *Parser) ParseForest(w string) (*Forest, error) {
+	r := &glr{parser, make(map[forestKey]*ForestNode)}
+	terminals := parser.augG.GetTerminals()
+	lex := parser.lexer()
+	lex.GetReader(w)
+
+	frontier := []*gssNode{{0, 0, []*gssEdge{}}}
+	spans := []Span{}
+	for level := 0; ; level++ {
+		token := lex.GetNextToken()
+		spans = append(spans, token.Span)
+		a := getTerminalFrom(terminals, token.Name)
+		if a == nil {
+			return nil, errors.New(fmt.Sprintf("unknown token: %v\n", token))
+		}
+
+		var accepted bool
+		var shifts []glrShift
+		frontier, shifts, accepted = r.reduceAll(frontier, level, a)
+		if accepted {
+			root, ok := r.nodes[forestKey{parser.augG.StartSymbol, 0, level}]
+			if !ok {
+				return nil, errors.New(fmt.Sprintf("no derivation of %v", parser.augG.StartSymbol.Sig))
+			}
+			return &Forest{root, parser.augG, spans}, nil
+		}
+		if len(shifts) == 0 {
+			line, column := tokenPosition(lex, token)
+			expects := []*ProductElem{}
+			for _, node := range frontier {
+				for _, term := range getCandidatesFromActionTable(parser, node.state) {
+					if !hasElem(expects, term) {
+						expects = append(expects, term)
+					}
+				}
+			}
+			return nil, errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. expects one of %v, but actual '%v'\n", line, column, expects, token))
+		}
+
+		leaf := r.forestNode(a, level, level+1)
+		leaf.Token = &token
+		next := []*gssNode{}
+		byState := make(map[int]*gssNode)
+		for _, s := range shifts {
+			node, ok := byState[s.state]
+			if !ok {
+				node = &gssNode{s.state, level + 1, []*gssEdge{}}
+				byState[s.state] = node
+				next = append(next, node)
+			}
+			node.addEdge(s.from, leaf)
+		}
+		frontier = next
+	}
+}
+
+// reduceAll performs every reduction possible on lookahead a in the stack
+// tops of level, and returns the resulting tops, the shifts they can make
+// and whether one of them accepts. When a reduction adds an edge to a top,
+// the tops already processed are processed again, since their paths may go
+// through the new edge and enable further reductions; reductions are
+// idempotent, so this only finishes the work.
+func (r *glr) reduceAll(frontier []*gssNode, level int, a *ProductElem) ([]*gssNode, []glrShift, bool) {
+	byState := make(map[int]*gssNode)
+	for _, node := range frontier {
+		byState[node.state] = node
+	}
+	processed := make(map[*gssNode]bool)
+	queue := append([]*gssNode{}, frontier...)
+	accepted := false
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		processed[node] = true
+		for _, act := range r.parser.actionTable.all(node.state, a) {
+			switch act.op {
+			case acceptAction:
+				accepted = true
+			case reduceAction:
+				prod := act.prod
+				length := 0
+				for _, elem := range prod.Body {
+					if elem != EmptyElem {
+						length++
+					}
+				}
+				for _, path := range node.paths(length) {
+					goTo, err := r.parser.gotoTable.get(path.to.state, prod.Head)
+					if err != nil {
+						continue
+					}
+					forestNode := r.forestNode(prod.Head, path.to.level, level)
+					forestNode.addAlternative(prod, path.children)
+
+					top, ok := byState[goTo]
+					if !ok {
+						top = &gssNode{goTo, level, []*gssEdge{}}
+						byState[goTo] = top
+						frontier = append(frontier, top)
+						queue = append(queue, top)
+					}
+					if top.addEdge(path.to, forestNode) {
+						for _, done := range frontier {
+							if processed[done] {
+								processed[done] = false
+								queue = append(queue, done)
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+
+	shifts := []glrShift{}
+	for _, node := range frontier {
+		for _, act := range r.parser.actionTable.all(node.state, a) {
+			if act.op == shiftAction {
+				shifts = append(shifts, glrShift{node, act.state})
+			}
+		}
+	}
+	return frontier, shifts, accepted
+}
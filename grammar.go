@@ -2,6 +2,7 @@ package gdpgen
 
 import (
 	"fmt"
+	"reflect"
 )
 
 const (
@@ -81,13 +82,110 @@ func (p Product) String() string {
 	return fmt.Sprintf("%v -> %v", p.Head.Sig, p.Body)
 }
 
+// G is a context-free grammar. Nullable, First, FirstOfSequence and Follow
+// cache their analysis in g, so they must not be called while g is used by
+// another goroutine; a Product changed in place after they were called is
+// only seen once another product is added with AddProduct.
 type G struct {
 	StartSymbol *ProductElem
 	Products    []*Product
+	patterns    []Pattern
+	meta        map[*Product]*productMeta
+	synthesized map[string]*ProductElem
+	precs       map[*ProductElem]precedence
+	expect      *[2]int
+	index       *grammarIndex
+	symbols     *symbolTable
+	// types are the result types declared for non-terminals by NonTerm
+	types map[*ProductElem][]reflect.Type
+}
+
+// productMeta holds the per-product settings that are not part of Product.
+type productMeta struct {
+	label string
+	prec  *ProductElem
+	typed bool // added by a typed rule such as Rule2
+
+	spanCallback func([]interface{}, Span) interface{}
 }
 
 func NewGrammar(startSymbol *ProductElem) *G {
-	return &G{startSymbol, []*Product{}}
+	symbols := newSymbolTable()
+	return &G{
+		symbols.intern(startSymbol),
+		[]*Product{},
+		[]Pattern{},
+		make(map[*Product]*productMeta),
+		make(map[string]*ProductElem),
+		make(map[*ProductElem]precedence),
+		nil,
+		nil,
+		symbols,
+		make(map[*ProductElem][]reflect.Type),
+	}
+}
+
+func (g *G) metaOf(p *Product) *productMeta {
+	if g.meta == nil {
+		g.meta = make(map[*Product]*productMeta)
+	}
+	m, ok := g.meta[p]
+	if !ok {
+		m = &productMeta{}
+		g.meta[p] = m
+	}
+	return m
+}
+
+// SetLabel names p so that it can be looked up with ProductByLabel.
+func (g *G) SetLabel(p *Product, label string) {
+	g.metaOf(p).label = label
+}
+
+// SetSpanCallback makes the value of p the result of callback, which gets
+// the values of the body like Product.Callback together with the span of
+// the input they cover. It takes the place of p.Callback.
+func (g *G) SetSpanCallback(p *Product, callback func([]interface{}, Span) interface{}) {
+	g.metaOf(p).spanCallback = callback
+}
+
+// Label returns the label of p, or "" if it has none.
+func (g *G) Label(p *Product) string {
+	if m, ok := g.meta[p]; ok {
+		return m.label
+	}
+	return ""
+}
+
+// ProductByLabel returns the product labeled label, or nil.
+func (g *G) ProductByLabel(label string) *Product {
+	for _, p := range g.Products {
+		if label != "" && g.Label(p) == label {
+			return p
+		}
+	}
+	return nil
+}
+
+// AddPattern registers the regex pattern used to lex the terminal called name.
+func (g *G) AddPattern(name, pattern string) {
+	for _, p := range g.patterns {
+		if p.Name == name {
+			return
+		}
+	}
+	g.patterns = append(g.patterns, NewPattern(name, pattern))
+}
+
+// NewLexer returns a RegexLexer configured with the patterns of g.
+func (g *G) NewLexer() Lexer {
+	lex := &RegexLexer{
+		[]rune{},
+		make([]Pattern, len(g.patterns)),
+		0, 1, 1,
+	}
+	copy(lex.patterns, g.patterns)
+	return lex
 }
 
 func (g *G) GetSymbolSet() []*ProductElem {
@@ -134,8 +232,12 @@ func (g *G) GetTerminals() []*ProductElem {
 	return terminals
 }
 
+// AddProduct adds p to g. Symbols of p with the same kind and name as a
+// symbol g already has are replaced by that symbol.
 func (g *G) AddProduct(p *Product) {
+	g.canonicalize(p)
 	g.Products = append(g.Products, p)
+	g.index = nil
 }
 
 func (g *G) GetProductsOf(head *ProductElem) []*Product {
@@ -0,0 +1,894 @@
+package gdpgen
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"unicode"
+)
+
+// ParseGrammar reads a grammar written in a BNF-like text format and returns
+// the corresponding *G. Terminal patterns are registered on the grammar, so
+// g.NewLexer() returns a RegexLexer ready to tokenize input for it.
+//
+//	# comments run to the end of the line
+//	%token number /\d+/      terminal with a regex pattern
+//	%token PLUS "+"          terminal matching a literal string
+//	%start expr              start symbol (default: head of the first rule)
+//	%left "+" "-"            operator precedence, later lines bind tighter;
+//	%right "^"               also %nonassoc
+//	%expect 1                expected shift/reduce conflicts, and
+//	%expect-rr 0             reduce/reduce ones (see NewParserWithOptions)
+//
+//	expr : expr "+" term {add}   quoted strings are literal terminals,
+//	     | term                  {label} names the production
+//	     ;
+//	opt  : %empty | "x" ;        %empty (or nothing) is the empty body
+//	neg  : "-" expr %prec UMINUS ;   %prec overrides the product precedence
+//
+//	args : "(" (expr ** ",") ")" ;   EBNF: x? x* x+ (a b | c), and
+//	                                 x ** sep / x ++ sep for zero/one or more
+//	                                 x separated by sep (see G.Optional etc.)
+//
+// Identifiers declared with %token are terminals, every other identifier
+// must be defined by a rule. Literal terminals are tried by the lexer before
+// the %token patterns, longest first.
+func ParseGrammar(r io.Reader) (*G, error) {
+	src, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	gp := &grammarParser{
+		scan:      newGrammarScanner(string(src)),
+		terminals: make(map[string]*ProductElem),
+		nonTerms:  make(map[string]*ProductElem),
+		labels:    make(map[string]bool),
+	}
+	return gp.parse()
+}
+
+// GrammarError reports a malformed grammar file.
+type GrammarError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *GrammarError) Error() string {
+	return fmt.Sprintf("grammar error at line:%v, column:%v: %v", e.Line, e.Column, e.Msg)
+}
+
+type grammarTokenKind int
+
+const (
+	gtEOF grammarTokenKind = iota
+	gtIdent
+	gtDirective
+	gtString
+	gtRegex
+	gtLabel
+	gtColon
+	gtBar
+	gtSemicolon
+	gtLParen
+	gtRParen
+	gtQuestion
+	gtStar
+	gtPlus
+	gtStarStar
+	gtPlusPlus
+	gtNumber
+)
+
+func (k grammarTokenKind) String() string {
+	switch k {
+	case gtEOF:
+		return "end of file"
+	case gtIdent:
+		return "identifier"
+	case gtDirective:
+		return "directive"
+	case gtString:
+		return "string"
+	case gtRegex:
+		return "regex"
+	case gtLabel:
+		return "label"
+	case gtColon:
+		return "':'"
+	case gtBar:
+		return "'|'"
+	case gtSemicolon:
+		return "';'"
+	case gtLParen:
+		return "'('"
+	case gtRParen:
+		return "')'"
+	case gtQuestion:
+		return "'?'"
+	case gtStar:
+		return "'*'"
+	case gtPlus:
+		return "'+'"
+	case gtStarStar:
+		return "'**'"
+	case gtPlusPlus:
+		return "'++'"
+	case gtNumber:
+		return "number"
+	}
+	return "unknown"
+}
+
+type grammarToken struct {
+	kind   grammarTokenKind
+	text   string
+	line   int
+	column int
+}
+
+func (t grammarToken) String() string {
+	switch t.kind {
+	case gtIdent, gtDirective, gtNumber:
+		return fmt.Sprintf("%v %q", t.kind, t.text)
+	case gtString:
+		return fmt.Sprintf("%q", t.text)
+	case gtRegex:
+		return "/" + t.text + "/"
+	case gtLabel:
+		return "{" + t.text + "}"
+	}
+	return t.kind.String()
+}
+
+type grammarScanner struct {
+	src    []rune
+	pos    int
+	line   int
+	column int
+	peeked *grammarToken
+}
+
+func newGrammarScanner(src string) *grammarScanner {
+	return &grammarScanner{[]rune(src), 0, 1, 1, nil}
+}
+
+func (s *grammarScanner) errorf(line, column int, format string, args ...interface{}) error {
+	return &GrammarError{line, column, fmt.Sprintf(format, args...)}
+}
+
+func (s *grammarScanner) advance() rune {
+	c := s.src[s.pos]
+	s.pos++
+	if c == '\n' {
+		s.line++
+		s.column = 1
+	} else {
+		s.column++
+	}
+	return c
+}
+
+func (s *grammarScanner) skipSpaceAndComments() {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		if unicode.IsSpace(c) {
+			s.advance()
+		} else if c == '#' {
+			for s.pos < len(s.src) && s.src[s.pos] != '\n' {
+				s.advance()
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (s *grammarScanner) peek() (grammarToken, error) {
+	if s.peeked == nil {
+		tok, err := s.scan()
+		if err != nil {
+			return tok, err
+		}
+		s.peeked = &tok
+	}
+	return *s.peeked, nil
+}
+
+// peekSecond returns the token after the next one without consuming either.
+func (s *grammarScanner) peekSecond() (grammarToken, error) {
+	if _, err := s.peek(); err != nil {
+		return grammarToken{}, err
+	}
+	saved := *s
+	s.peeked = nil
+	tok, err := s.scan()
+	*s = saved
+	return tok, err
+}
+
+func (s *grammarScanner) next() (grammarToken, error) {
+	tok, err := s.peek()
+	s.peeked = nil
+	return tok, err
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return c == '_' || c == '-' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+func (s *grammarScanner) scan() (grammarToken, error) {
+	s.skipSpaceAndComments()
+	line, column := s.line, s.column
+	if s.pos >= len(s.src) {
+		return grammarToken{gtEOF, "", line, column}, nil
+	}
+	c := s.src[s.pos]
+	switch {
+	case c == ':':
+		s.advance()
+		return grammarToken{gtColon, ":", line, column}, nil
+	case c == '|':
+		s.advance()
+		return grammarToken{gtBar, "|", line, column}, nil
+	case c == ';':
+		s.advance()
+		return grammarToken{gtSemicolon, ";", line, column}, nil
+	case c == '(':
+		s.advance()
+		return grammarToken{gtLParen, "(", line, column}, nil
+	case c == ')':
+		s.advance()
+		return grammarToken{gtRParen, ")", line, column}, nil
+	case c == '?':
+		s.advance()
+		return grammarToken{gtQuestion, "?", line, column}, nil
+	case c == '*' || c == '+':
+		s.advance()
+		if s.pos < len(s.src) && s.src[s.pos] == c {
+			s.advance()
+			if c == '*' {
+				return grammarToken{gtStarStar, "**", line, column}, nil
+			}
+			return grammarToken{gtPlusPlus, "++", line, column}, nil
+		}
+		if c == '*' {
+			return grammarToken{gtStar, "*", line, column}, nil
+		}
+		return grammarToken{gtPlus, "+", line, column}, nil
+	case c == '%':
+		s.advance()
+		start := s.pos
+		for s.pos < len(s.src) && isIdentPart(s.src[s.pos]) {
+			s.advance()
+		}
+		if start == s.pos {
+			return grammarToken{}, s.errorf(line, column, "expected directive name after '%%'")
+		}
+		return grammarToken{gtDirective, string(s.src[start:s.pos]), line, column}, nil
+	case c == '"' || c == '\'':
+		return s.scanString(c, line, column)
+	case c == '/':
+		return s.scanRegex(line, column)
+	case c == '{':
+		s.advance()
+		s.skipSpaceAndComments()
+		start := s.pos
+		for s.pos < len(s.src) && isIdentPart(s.src[s.pos]) {
+			s.advance()
+		}
+		label := string(s.src[start:s.pos])
+		s.skipSpaceAndComments()
+		if label == "" || s.pos >= len(s.src) || s.src[s.pos] != '}' {
+			return grammarToken{}, s.errorf(line, column, "malformed label, expected {name}")
+		}
+		s.advance()
+		return grammarToken{gtLabel, label, line, column}, nil
+	case unicode.IsDigit(c):
+		start := s.pos
+		for s.pos < len(s.src) && unicode.IsDigit(s.src[s.pos]) {
+			s.advance()
+		}
+		return grammarToken{gtNumber, string(s.src[start:s.pos]), line, column}, nil
+	case isIdentStart(c):
+		start := s.pos
+		for s.pos < len(s.src) && isIdentPart(s.src[s.pos]) {
+			s.advance()
+		}
+		return grammarToken{gtIdent, string(s.src[start:s.pos]), line, column}, nil
+	}
+	return grammarToken{}, s.errorf(line, column, "unexpected character %q", c)
+}
+
+func (s *grammarScanner) scanString(quote rune, line, column int) (grammarToken, error) {
+	s.advance()
+	var value []rune
+	for {
+		if s.pos >= len(s.src) || s.src[s.pos] == '\n' {
+			return grammarToken{}, s.errorf(line, column, "unterminated string literal")
+		}
+		c := s.advance()
+		if c == quote {
+			break
+		}
+		if c == '\\' {
+			if s.pos >= len(s.src) {
+				return grammarToken{}, s.errorf(line, column, "unterminated string literal")
+			}
+			escLine, escColumn := s.line, s.column
+			switch e := s.advance(); e {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case 'r':
+				c = '\r'
+			case '\\', '"', '\'':
+				c = e
+			default:
+				return grammarToken{}, s.errorf(escLine, escColumn-1, "unknown escape sequence \\%c", e)
+			}
+		}
+		value = append(value, c)
+	}
+	if len(value) == 0 {
+		return grammarToken{}, s.errorf(line, column, "empty string literal")
+	}
+	return grammarToken{gtString, string(value), line, column}, nil
+}
+
+func (s *grammarScanner) scanRegex(line, column int) (grammarToken, error) {
+	s.advance()
+	var value []rune
+	for {
+		if s.pos >= len(s.src) || s.src[s.pos] == '\n' {
+			return grammarToken{}, s.errorf(line, column, "unterminated regex")
+		}
+		c := s.advance()
+		if c == '/' {
+			break
+		}
+		if c == '\\' && s.pos < len(s.src) && s.src[s.pos] == '/' {
+			c = s.advance()
+		} else if c == '\\' && s.pos < len(s.src) {
+			value = append(value, c)
+			c = s.advance()
+		}
+		value = append(value, c)
+	}
+	if len(value) == 0 {
+		return grammarToken{}, s.errorf(line, column, "empty regex")
+	}
+	return grammarToken{gtRegex, string(value), line, column}, nil
+}
+
+type grammarRule struct {
+	head grammarToken
+	alts []*grammarAlt
+}
+
+type grammarAlt struct {
+	pos   grammarToken
+	elems []*grammarExpr
+	label string
+	prec  *grammarToken
+}
+
+type grammarPrecDecl struct {
+	assoc Assoc
+	terms []grammarToken
+}
+
+type grammarExprKind int
+
+const (
+	geSymbol grammarExprKind = iota
+	geOptional
+	geMany
+	geMany1
+	geSepBy
+	geSepBy1
+	geGroup
+)
+
+// grammarExpr is one element of a rule body: a symbol or an EBNF construct.
+type grammarExpr struct {
+	kind grammarExprKind
+	tok  grammarToken
+	sub  *grammarExpr
+	sep  *grammarExpr
+	alts [][]*grammarExpr
+}
+
+type grammarParser struct {
+	scan      *grammarScanner
+	terminals map[string]*ProductElem
+	nonTerms  map[string]*ProductElem
+	labels    map[string]bool
+	tokens    [][2]string
+	literals  []string
+	start     *grammarToken
+	precs     []grammarPrecDecl
+	rules     []*grammarRule
+
+	expectCounts *[2]int
+}
+
+func (gp *grammarParser) errorAt(tok grammarToken, format string, args ...interface{}) error {
+	return &GrammarError{tok.line, tok.column, fmt.Sprintf(format, args...)}
+}
+
+func (gp *grammarParser) expect(kind grammarTokenKind, context string) (grammarToken, error) {
+	tok, err := gp.scan.next()
+	if err != nil {
+		return tok, err
+	}
+	if tok.kind != kind {
+		return tok, gp.errorAt(tok, "expected %v %v, but got %v", kind, context, tok)
+	}
+	return tok, nil
+}
+
+func (gp *grammarParser) parse() (*G, error) {
+	for {
+		tok, err := gp.scan.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case gtEOF:
+			return gp.build()
+		case gtDirective:
+			if err := gp.parseDirective(); err != nil {
+				return nil, err
+			}
+		case gtIdent:
+			if err := gp.parseRule(); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, gp.errorAt(tok, "expected a rule or a directive, but got %v", tok)
+		}
+	}
+}
+
+func (gp *grammarParser) parseDirective() error {
+	dir, _ := gp.scan.next()
+	switch dir.text {
+	case "token":
+		name, err := gp.expect(gtIdent, "after %token")
+		if err != nil {
+			return err
+		}
+		if _, ok := gp.terminals[name.text]; ok {
+			return gp.errorAt(name, "terminal %q is declared twice", name.text)
+		}
+		pat, err := gp.scan.next()
+		if err != nil {
+			return err
+		}
+		var pattern string
+		switch pat.kind {
+		case gtRegex:
+			pattern = pat.text
+		case gtString:
+			pattern = regexp.QuoteMeta(pat.text)
+		default:
+			return gp.errorAt(pat, "expected a /regex/ or a \"literal\" for terminal %q, but got %v", name.text, pat)
+		}
+		if _, err := regexp.Compile("^" + pattern); err != nil {
+			return gp.errorAt(pat, "invalid pattern for terminal %q: %v", name.text, err)
+		}
+		gp.terminals[name.text] = NewTerminal(name.text)
+		gp.tokens = append(gp.tokens, [2]string{name.text, pattern})
+	case "start":
+		name, err := gp.expect(gtIdent, "after %start")
+		if err != nil {
+			return err
+		}
+		if gp.start != nil {
+			return gp.errorAt(dir, "%%start is declared twice")
+		}
+		gp.start = &name
+	case "expect", "expect-rr":
+		num, err := gp.expect(gtNumber, "after %"+dir.text)
+		if err != nil {
+			return err
+		}
+		n, err := strconv.Atoi(num.text)
+		if err != nil {
+			return gp.errorAt(num, "invalid count %v for %%%v", num.text, dir.text)
+		}
+		if gp.expectCounts == nil {
+			gp.expectCounts = &[2]int{0, 0}
+		}
+		if dir.text == "expect" {
+			gp.expectCounts[0] = n
+		} else {
+			gp.expectCounts[1] = n
+		}
+	case "left", "right", "nonassoc":
+		decl := grammarPrecDecl{LEFT_ASSOC, nil}
+		if dir.text == "right" {
+			decl.assoc = RIGHT_ASSOC
+		} else if dir.text == "nonassoc" {
+			decl.assoc = NON_ASSOC
+		}
+		for {
+			tok, err := gp.scan.peek()
+			if err != nil {
+				return err
+			}
+			if tok.kind != gtString && tok.kind != gtIdent {
+				break
+			}
+			// an identifier followed by ':' starts the next rule
+			if tok.kind == gtIdent {
+				if second, err := gp.scan.peekSecond(); err == nil && second.kind == gtColon {
+					break
+				}
+			}
+			gp.scan.next()
+			decl.terms = append(decl.terms, tok)
+		}
+		if len(decl.terms) == 0 {
+			return gp.errorAt(dir, "%%%v needs at least one terminal", dir.text)
+		}
+		gp.precs = append(gp.precs, decl)
+	default:
+		return gp.errorAt(dir, "unknown directive %%%v", dir.text)
+	}
+	return nil
+}
+
+// precTerminal resolves a terminal named in %left, %right, %nonassoc or %prec.
+func (gp *grammarParser) precTerminal(tok grammarToken) (*ProductElem, error) {
+	if tok.kind == gtIdent {
+		if _, ok := gp.terminals[tok.text]; !ok {
+			if _, ok := gp.nonTerms[tok.text]; ok {
+				return nil, gp.errorAt(tok, "%q is a non-terminal and cannot have a precedence", tok.text)
+			}
+			// a name used only for %prec, like yacc's UMINUS
+			gp.terminals[tok.text] = NewTerminal(tok.text)
+		}
+	}
+	return gp.symbol(tok)
+}
+
+func (gp *grammarParser) parseRule() error {
+	head, _ := gp.scan.next()
+	if _, err := gp.expect(gtColon, fmt.Sprintf("after rule name %q", head.text)); err != nil {
+		return err
+	}
+	rule := &grammarRule{head: head}
+	for {
+		alt, err := gp.parseAlternative()
+		if err != nil {
+			return err
+		}
+		rule.alts = append(rule.alts, alt)
+		tok, err := gp.scan.next()
+		if err != nil {
+			return err
+		}
+		if tok.kind == gtSemicolon {
+			break
+		}
+		if tok.kind != gtBar {
+			return gp.errorAt(tok, "expected '|' or ';' in rule %q, but got %v", head.text, tok)
+		}
+	}
+	gp.rules = append(gp.rules, rule)
+	return nil
+}
+
+func (gp *grammarParser) parseAlternative() (*grammarAlt, error) {
+	first, err := gp.scan.peek()
+	if err != nil {
+		return nil, err
+	}
+	elems, err := gp.parseSequence()
+	if err != nil {
+		return nil, err
+	}
+	alt := &grammarAlt{pos: first, elems: elems}
+	tok, err := gp.scan.peek()
+	if err != nil {
+		return nil, err
+	}
+	if tok.kind == gtDirective && tok.text == "prec" {
+		gp.scan.next()
+		prec, err := gp.scan.next()
+		if err != nil {
+			return nil, err
+		}
+		if prec.kind != gtIdent && prec.kind != gtString {
+			return nil, gp.errorAt(prec, "expected a terminal after %%prec, but got %v", prec)
+		}
+		alt.prec = &prec
+		if tok, err = gp.scan.peek(); err != nil {
+			return nil, err
+		}
+	}
+	switch tok.kind {
+	case gtLabel:
+		gp.scan.next()
+		if gp.labels[tok.text] {
+			return nil, gp.errorAt(tok, "label %q is used twice", tok.text)
+		}
+		gp.labels[tok.text] = true
+		alt.label = tok.text
+	case gtBar, gtSemicolon:
+	default:
+		return nil, gp.errorAt(tok, "unexpected %v in rule body", tok)
+	}
+	return alt, nil
+}
+
+// parseSequence reads the symbols of one alternative up to a '|', ';', ')'
+// or label.
+func (gp *grammarParser) parseSequence() ([]*grammarExpr, error) {
+	elems := []*grammarExpr{}
+	empty := false
+	for {
+		tok, err := gp.scan.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case gtIdent, gtString, gtLParen:
+			if empty {
+				return nil, gp.errorAt(tok, "%%empty cannot be combined with other symbols")
+			}
+			expr, err := gp.parseItem()
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, expr)
+		case gtDirective:
+			if tok.text == "prec" {
+				return elems, nil
+			}
+			if tok.text != "empty" {
+				return nil, gp.errorAt(tok, "unexpected directive %%%v in rule body", tok.text)
+			}
+			if empty || len(elems) > 0 {
+				return nil, gp.errorAt(tok, "%%empty cannot be combined with other symbols")
+			}
+			gp.scan.next()
+			empty = true
+		default:
+			return elems, nil
+		}
+	}
+}
+
+// parseItem reads a symbol or parenthesized group followed by any number of
+// the postfix operators ?, *, +, ** sep and ++ sep.
+func (gp *grammarParser) parseItem() (*grammarExpr, error) {
+	expr, err := gp.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		tok, err := gp.scan.peek()
+		if err != nil {
+			return nil, err
+		}
+		switch tok.kind {
+		case gtQuestion:
+			expr = &grammarExpr{kind: geOptional, tok: tok, sub: expr}
+		case gtStar:
+			expr = &grammarExpr{kind: geMany, tok: tok, sub: expr}
+		case gtPlus:
+			expr = &grammarExpr{kind: geMany1, tok: tok, sub: expr}
+		case gtStarStar, gtPlusPlus:
+			gp.scan.next()
+			sep, err := gp.parsePrimary()
+			if err != nil {
+				return nil, err
+			}
+			kind := geSepBy
+			if tok.kind == gtPlusPlus {
+				kind = geSepBy1
+			}
+			expr = &grammarExpr{kind: kind, tok: tok, sub: expr, sep: sep}
+			continue
+		default:
+			return expr, nil
+		}
+		gp.scan.next()
+	}
+}
+
+func (gp *grammarParser) parsePrimary() (*grammarExpr, error) {
+	tok, err := gp.scan.next()
+	if err != nil {
+		return nil, err
+	}
+	switch tok.kind {
+	case gtIdent, gtString:
+		return &grammarExpr{kind: geSymbol, tok: tok}, nil
+	case gtLParen:
+		group := &grammarExpr{kind: geGroup, tok: tok}
+		for {
+			seq, err := gp.parseSequence()
+			if err != nil {
+				return nil, err
+			}
+			group.alts = append(group.alts, seq)
+			next, err := gp.scan.next()
+			if err != nil {
+				return nil, err
+			}
+			if next.kind == gtRParen {
+				return group, nil
+			}
+			if next.kind != gtBar {
+				return nil, gp.errorAt(next, "expected '|' or ')' in group opened at line:%v, column:%v, but got %v", tok.line, tok.column, next)
+			}
+		}
+	}
+	return nil, gp.errorAt(tok, "expected a symbol or '(', but got %v", tok)
+}
+
+// resolve turns expr into a symbol of g, synthesizing EBNF non-terminals.
+func (gp *grammarParser) resolve(g *G, expr *grammarExpr) (*ProductElem, error) {
+	if expr.kind == geSymbol {
+		return gp.symbol(expr.tok)
+	}
+	if expr.kind == geGroup {
+		alts := [][]*ProductElem{}
+		for _, seq := range expr.alts {
+			alt := []*ProductElem{}
+			for _, e := range seq {
+				elem, err := gp.resolve(g, e)
+				if err != nil {
+					return nil, err
+				}
+				alt = append(alt, elem)
+			}
+			alts = append(alts, alt)
+		}
+		return g.Group(alts...), nil
+	}
+	sub, err := gp.resolve(g, expr.sub)
+	if err != nil {
+		return nil, err
+	}
+	switch expr.kind {
+	case geOptional:
+		return g.Optional(sub), nil
+	case geMany:
+		return g.Many(sub), nil
+	case geMany1:
+		return g.Many1(sub), nil
+	}
+	sep, err := gp.resolve(g, expr.sep)
+	if err != nil {
+		return nil, err
+	}
+	if expr.kind == geSepBy1 {
+		return g.SepBy1(sub, sep), nil
+	}
+	return g.SepBy(sub, sep), nil
+}
+
+func (gp *grammarParser) symbol(tok grammarToken) (*ProductElem, error) {
+	if tok.kind == gtString {
+		if term, ok := gp.terminals[tok.text]; ok {
+			return term, nil
+		}
+		if _, ok := gp.nonTerms[tok.text]; ok {
+			return nil, gp.errorAt(tok, "literal %q has the name of a rule", tok.text)
+		}
+		term := NewTerminal(tok.text)
+		gp.terminals[tok.text] = term
+		gp.literals = append(gp.literals, tok.text)
+		return term, nil
+	}
+	if term, ok := gp.terminals[tok.text]; ok {
+		return term, nil
+	}
+	if nonTerm, ok := gp.nonTerms[tok.text]; ok {
+		return nonTerm, nil
+	}
+	return nil, gp.errorAt(tok, "undefined symbol %q", tok.text)
+}
+
+func (gp *grammarParser) build() (*G, error) {
+	if len(gp.rules) == 0 {
+		tok, _ := gp.scan.peek()
+		return nil, gp.errorAt(tok, "grammar has no rules")
+	}
+
+	// declare every rule head first so rules may refer to later ones
+	for _, rule := range gp.rules {
+		name := rule.head.text
+		if _, ok := gp.terminals[name]; ok {
+			return nil, gp.errorAt(rule.head, "%q is declared as a terminal but used as a rule name", name)
+		}
+		if _, ok := gp.nonTerms[name]; !ok {
+			gp.nonTerms[name] = NewNonTerminal(name)
+		}
+	}
+
+	startTok := gp.rules[0].head
+	if gp.start != nil {
+		startTok = *gp.start
+	}
+	start, ok := gp.nonTerms[startTok.text]
+	if !ok {
+		return nil, gp.errorAt(startTok, "start symbol %q is not defined by any rule", startTok.text)
+	}
+
+	g := NewGrammar(start)
+	if gp.expectCounts != nil {
+		g.Expect(gp.expectCounts[0], gp.expectCounts[1])
+	}
+	for _, decl := range gp.precs {
+		terms := []*ProductElem{}
+		for _, tok := range decl.terms {
+			term, err := gp.precTerminal(tok)
+			if err != nil {
+				return nil, err
+			}
+			if _, _, ok := g.Precedence(term); ok {
+				return nil, gp.errorAt(tok, "precedence of %q is declared twice", tok.text)
+			}
+			terms = append(terms, term)
+		}
+		g.declarePrecedence(decl.assoc, terms)
+	}
+	for _, rule := range gp.rules {
+		head := gp.nonTerms[rule.head.text]
+		for _, alt := range rule.alts {
+			product := NewProduct(head)
+			for _, expr := range alt.elems {
+				elem, err := gp.resolve(g, expr)
+				if err != nil {
+					return nil, err
+				}
+				product.Body = append(product.Body, elem)
+			}
+			if len(product.Body) == 0 {
+				product.Body = []*ProductElem{EmptyElem}
+			}
+			if g.GetProductOf(head, product.Body) != nil {
+				return nil, gp.errorAt(alt.pos, "duplicate alternative %v in rule %q", product.Body, rule.head.text)
+			}
+			g.AddProduct(product)
+			if alt.label != "" {
+				g.SetLabel(product, alt.label)
+			}
+			if alt.prec != nil {
+				term, err := gp.precTerminal(*alt.prec)
+				if err != nil {
+					return nil, err
+				}
+				g.SetPrec(product, term)
+			}
+		}
+	}
+
+	literals := make([]string, len(gp.literals))
+	copy(literals, gp.literals)
+	sort.SliceStable(literals, func(i, j int) bool {
+		return len(literals[i]) > len(literals[j])
+	})
+	for _, lit := range literals {
+		g.AddPattern(lit, regexp.QuoteMeta(lit))
+	}
+	for _, token := range gp.tokens {
+		g.AddPattern(token[0], token[1])
+	}
+
+	return g, nil
+}
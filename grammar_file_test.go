@@ -0,0 +1,90 @@
+package gdpgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseGrammarErrors(t *testing.T) {
+	tests := []struct {
+		src    string
+		line   int
+		column int
+		msg    string
+	}{
+		{"", 1, 1, "grammar has no rules"},
+		{"s : \"a\" ;;", 1, 10, "expected a rule or a directive"},
+		{"%foo\ns : \"a\" ;", 1, 1, "unknown directive %foo"},
+		{"%token id /[a-z]+/\n%token id /x/\ns : id ;", 2, 8, `terminal "id" is declared twice`},
+		{"%token id /(/\ns : id ;", 1, 11, `invalid pattern for terminal "id"`},
+		{"%token id 42\ns : id ;", 1, 11, `expected a /regex/ or a "literal"`},
+		{"s : \"a\" \n  \"b\" t ;", 2, 7, `undefined symbol "t"`},
+		{"s : \"a\" | \"a\" ;", 1, 11, "duplicate alternative"},
+		{"s : \"a ;", 1, 5, "unterminated string literal"},
+		{"s : \"\" ;", 1, 5, "empty string literal"},
+		{"s : ( \"a\" \"b\" ;", 1, 15, "expected '|' or ')' in group opened at line:1, column:5"},
+		{"s : %empty \"a\" ;", 1, 12, "%empty cannot be combined with other symbols"},
+		{"s : \"a\" {x} | \"b\" {x} ;", 1, 19, `label "x" is used twice`},
+		{"%left s\ns : \"a\" ;", 1, 7, `"s" is a non-terminal and cannot have a precedence`},
+		{"%start t\ns : \"a\" ;", 1, 8, `start symbol "t" is not defined by any rule`},
+		{"%token s /s/\ns : \"a\" ;", 2, 1, `"s" is declared as a terminal but used as a rule name`},
+		{"%expect x\ns : \"a\" ;", 1, 9, "expected number after %expect"},
+		{"s : t | \"t\" ;\nt : \"a\" ;", 1, 9, `literal "t" has the name of a rule`},
+		{"s : \"a\" @ ;", 1, 9, `unexpected character '@'`},
+	}
+	for _, tc := range tests {
+		_, err := ParseGrammar(strings.NewReader(tc.src))
+		ge, ok := err.(*GrammarError)
+		if !ok {
+			t.Errorf("ParseGrammar(%q) = %v, want a *GrammarError", tc.src, err)
+			continue
+		}
+		if ge.Line != tc.line || ge.Column != tc.column || !strings.Contains(ge.Msg, tc.msg) {
+			t.Errorf("ParseGrammar(%q) = %v:%v %q, want %v:%v %q", tc.src, ge.Line, ge.Column, ge.Msg, tc.line, tc.column, tc.msg)
+		}
+	}
+}
+
+func TestParseGrammar(t *testing.T) {
+	src := `
+# a comment
+%token id /[a-z]+/
+%token num /\d+/
+%start list
+%expect 1
+%expect-rr 2
+
+list : item | list "," item {append} ;
+item : id | num | "(" list ")" | %empty ;
+`
+	g, err := ParseGrammar(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.StartSymbol.Sig != "list" {
+		t.Errorf("start symbol %v, want list", g.StartSymbol.Sig)
+	}
+	if *g.expect != [2]int{1, 2} {
+		t.Errorf("expect %v, want [1 2]", *g.expect)
+	}
+	if p := g.ProductByLabel("append"); p == nil || p.Head.Sig != "list" || len(p.Body) != 3 || g.Label(p) != "append" {
+		t.Errorf("product labeled append = %v", p)
+	}
+	if n := len(g.Products); n != 6 {
+		t.Errorf("%v products, want 6", n)
+	}
+
+	lex := g.NewLexer()
+	lex.GetReader("( 12, abc )")
+	names := []string{}
+	for token := lex.GetNextToken(); token.Name != "$"; token = lex.GetNextToken() {
+		names = append(names, token.Name)
+	}
+	if got := strings.Join(names, " "); got != "( num , id )" {
+		t.Errorf("tokens %v, want ( num , id )", got)
+	}
+
+	if _, err := NewParser(g, g.NewLexer()).Parse("a, (1, b), ()"); err != nil {
+		t.Errorf("Parse: %v", err)
+	}
+}
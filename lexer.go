@@ -1,7 +1,9 @@
 package gdpgen
 
 import (
+	"fmt"
 	"regexp"
+	"unicode/utf8"
 )
 
 type Lexer interface {
@@ -11,9 +13,62 @@ type Lexer interface {
 	GetCurrentPosition() (int, int)
 }
 
+// LexerCloner is implemented by lexers that can make a new lexer with the
+// same patterns and a fresh reading state. Parsers clone such a lexer for
+// every parse, which makes parsing safe for concurrent use.
+type LexerCloner interface {
+	Clone() Lexer
+}
+
+// Token is a lexeme returned by a Lexer. Since it embeds a Span, Token
+// literals must name their fields, e.g. Token{Name: name, Value: value};
+// the unkeyed Token{name, value} of earlier versions no longer compiles.
 type Token struct {
 	Name  string
 	Value string
+	Span
+}
+
+// Span is the part of the input covered by a token or a reduced product:
+// byte offsets Start and End, and the lines and columns where it starts and
+// ends. Lines and columns count from 1, columns in runes; the end is
+// exclusive.
+type Span struct {
+	Start     int
+	End       int
+	Line      int
+	Column    int
+	EndLine   int
+	EndColumn int
+}
+
+func (s Span) String() string {
+	return fmt.Sprintf("%v:%v-%v:%v", s.Line, s.Column, s.EndLine, s.EndColumn)
+}
+
+// emptySpan returns the empty span where s starts.
+func emptySpan(s Span) Span {
+	return Span{s.Start, s.Start, s.Line, s.Column, s.Line, s.Column}
+}
+
+// joinSpans returns the span from the start of first to the end of last.
+func joinSpans(first, last Span) Span {
+	return Span{first.Start, last.End, first.Line, first.Column, last.EndLine, last.EndColumn}
+}
+
+// tokenPosition returns the line and column where token starts. Lexers that
+// leave the Span of their tokens empty are asked for their position, which
+// is taken to be just after token.
+func tokenPosition(lex Lexer, token Token) (int, int) {
+	if token.Line > 0 {
+		return token.Line, token.Column
+	}
+	line, column := lex.GetCurrentPosition()
+	return line, column - len(token.Value)
+}
+
+func (t Token) String() string {
+	return fmt.Sprintf("{%v %v}", t.Name, t.Value)
 }
 
 type Pattern struct {
@@ -32,13 +87,14 @@ func NewPattern(name string, regexPattern string) Pattern {
 type RegexLexer struct {
 	chars    []rune
 	patterns []Pattern
-	pos      int
-	line     int
+	pos      int // byte offset of chars in the input
+	line     int // line and column of chars
 	column   int
 }
 
 func (l *RegexLexer) GetReader(s string) {
 	l.chars = []rune(s)
+	l.pos, l.line, l.column = 0, 1, 1
 }
 
 func (l *RegexLexer) AddPattern(name, pattern string) {
@@ -51,47 +107,66 @@ func (l *RegexLexer) AddPattern(name, pattern string) {
 	l.patterns = append(l.patterns, NewPattern(name, pattern))
 }
 
+// GetNextToken returns the next token, or "$" at the end of the input. White
+// space and characters that no pattern matches are skipped, as they are by
+// the lexers GenerateGo writes.
 func (l *RegexLexer) GetNextToken() Token {
-	for i, c := range l.chars {
-		if c == ' ' || c == '\n' || c == '\r' || c == '\t' {
-			if c == '\n' {
-				l.line++
-				l.column = 1
-			} else {
-				l.column++
-			}
+	for len(l.chars) > 0 {
+		if c := l.chars[0]; c == ' ' || c == '\n' || c == '\r' || c == '\t' {
+			l.advance(1)
 			continue
 		}
+		rest := string(l.chars)
 		for _, p := range l.patterns {
-			mRange := p.Regex.FindStringIndex(string(l.chars[i:]))
+			mRange := p.Regex.FindStringIndex(rest)
 			if mRange != nil {
-				l.column = l.column + (mRange[1] - mRange[0])
-				value := l.chars[i+mRange[0] : i+mRange[1]]
-				if mRange[1] < len(l.chars) {
-					l.pos = l.pos + i + mRange[1]
-					l.chars = l.chars[i+mRange[1]:]
-				} else {
-					l.pos = l.pos + len(l.chars)
-					l.chars = []rune{}
-				}
-				return Token{p.Name, string(value)}
+				value := rest[mRange[0]:mRange[1]]
+				start := Span{l.pos, l.pos, l.line, l.column, l.line, l.column}
+				l.advance(utf8.RuneCountInString(rest[:mRange[1]]))
+				return Token{p.Name, value, joinSpans(start, Span{l.pos, l.pos, l.line, l.column, l.line, l.column})}
 			}
 		}
+		// characters no pattern matches are skipped
+		l.advance(1)
+	}
+	return Token{"$", "", Span{l.pos, l.pos, l.line, l.column, l.line, l.column}}
+}
+
+// advance moves past the next n runes.
+func (l *RegexLexer) advance(n int) {
+	for _, c := range l.chars[:n] {
+		if c == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
 	}
-	l.pos = l.pos + len(l.chars)
-	l.chars = []rune{}
-	return Token{"$", ""}
+	l.pos += len(string(l.chars[:n]))
+	l.chars = l.chars[n:]
 }
 
+// GetCurrentPosition returns the line and column after the last token read.
 func (l *RegexLexer) GetCurrentPosition() (int, int) {
 	return l.line, l.column
 }
 
+// Clone returns a lexer with the patterns of l that has not read anything.
+func (l *RegexLexer) Clone() Lexer {
+	lex := &RegexLexer{
+		[]rune{},
+		make([]Pattern, len(l.patterns)),
+		0, 1, 1,
+	}
+	copy(lex.patterns, l.patterns)
+	return lex
+}
+
 func NewRegexLexer() Lexer {
 	return &RegexLexer{
 		[]rune{},
 		[]Pattern{},
-		0, 1, 0,
+		0, 1, 1,
 	}
 }
 
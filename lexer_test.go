@@ -0,0 +1,57 @@
+package gdpgen
+
+import (
+	"testing"
+)
+
+func TestRegexLexerSpans(t *testing.T) {
+	lex := NewRegexLexer().(*RegexLexer)
+	lex.AddPattern("id", `[a-zé]+`)
+	lex.AddPattern("num", `\d+`)
+	lex.AddPattern("=", `=`)
+	input := "ab = 12\n  é2 ? x\n"
+	lex.GetReader(input)
+	want := []Token{
+		{"id", "ab", Span{0, 2, 1, 1, 1, 3}},
+		{"=", "=", Span{3, 4, 1, 4, 1, 5}},
+		{"num", "12", Span{5, 7, 1, 6, 1, 8}},
+		{"id", "é", Span{10, 12, 2, 3, 2, 4}},
+		{"num", "2", Span{12, 13, 2, 4, 2, 5}},
+		// ? is skipped
+		{"id", "x", Span{16, 17, 2, 8, 2, 9}},
+		{"$", "", Span{18, 18, 3, 1, 3, 1}},
+	}
+	for i, w := range want {
+		token := lex.GetNextToken()
+		if token != w {
+			t.Errorf("token %v = %+v, want %+v", i, token, w)
+		}
+		if token.Value != input[token.Start:token.End] {
+			t.Errorf("token %v: value %q, input %q", i, token.Value, input[token.Start:token.End])
+		}
+	}
+	if line, column := lex.GetCurrentPosition(); line != 3 || column != 1 {
+		t.Errorf("GetCurrentPosition() = %v, %v, want 3, 1", line, column)
+	}
+
+	clone := lex.Clone().(*RegexLexer)
+	lex.AddPattern("ws", `\s+`)
+	if len(clone.patterns) != 3 {
+		t.Errorf("AddPattern on l changed the patterns of its clone")
+	}
+	clone.GetReader("x")
+	if token := clone.GetNextToken(); token != (Token{"id", "x", Span{0, 1, 1, 1, 1, 2}}) {
+		t.Errorf("clone read %+v", token)
+	}
+}
+
+func TestJoinSpans(t *testing.T) {
+	a := Span{2, 4, 1, 3, 1, 5}
+	b := Span{7, 9, 2, 1, 2, 3}
+	if got := joinSpans(a, b); got != (Span{2, 9, 1, 3, 2, 3}) {
+		t.Errorf("joinSpans = %+v", got)
+	}
+	if got := emptySpan(b); got != (Span{7, 7, 2, 1, 2, 1}) {
+		t.Errorf("emptySpan = %+v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package gdpgen
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	FIRST_FIRST LLConflictKind = iota
+	FIRST_FOLLOW
+)
+
+// LLConflictKind tells why two products compete for an LL(1) table cell:
+// their bodies start with the same terminal, or one of them derives the
+// empty string and the terminal can follow the head.
+type LLConflictKind int
+
+func (k LLConflictKind) String() string {
+	switch k {
+	case FIRST_FIRST:
+		return "FIRST/FIRST"
+	case FIRST_FOLLOW:
+		return "FIRST/FOLLOW"
+	}
+	return "unknown"
+}
+
+// LLConflict is an LL(1) table cell with more than one product to predict.
+type LLConflict struct {
+	Kind        LLConflictKind
+	NonTerminal *ProductElem
+	Lookahead   *ProductElem
+	// Products are the competing products, in declaration order.
+	Products []*Product
+}
+
+func (c *LLConflict) String() string {
+	products := make([]string, len(c.Products))
+	for i, p := range c.Products {
+		products[i] = fmt.Sprintf("%v", p)
+	}
+	return fmt.Sprintf("%v conflict for %v on %v between %v",
+		c.Kind, c.NonTerminal.Sig, c.Lookahead.Sig, strings.Join(products, " and "))
+}
+
+// LLConflictError is returned by NewLLParser when g is not LL(1).
+type LLConflictError struct {
+	Conflicts []*LLConflict
+}
+
+func (e *LLConflictError) Error() string {
+	return fmt.Sprintf("grammar is not LL(1): %v conflicts, first: %v", len(e.Conflicts), e.Conflicts[0])
+}
+
+// llTable maps a non-terminal and a lookahead terminal to the products to
+// predict, by id.
+type llTable struct {
+	idx       *grammarIndex
+	cells     map[int]map[int][]int
+	conflicts []*LLConflict
+}
+
+func buildLLTable(idx *grammarIndex) *llTable {
+	t := &llTable{idx, make(map[int]map[int][]int), []*LLConflict{}}
+	byFollow := make(map[int]map[int]bool)
+	for p := range idx.bodies {
+		head := idx.heads[p]
+		if idx.symbols[head] == augStartElem {
+			continue
+		}
+		if _, ok := t.cells[head]; !ok {
+			t.cells[head] = make(map[int][]int)
+			byFollow[head] = make(map[int]bool)
+		}
+		for _, term := range idx.firstAfter[p][0].members() {
+			t.add(head, term, p)
+		}
+		if idx.nullableAfter[p][0] {
+			for _, term := range idx.follow[head].members() {
+				t.add(head, term, p)
+				byFollow[head][term] = true
+			}
+		}
+	}
+
+	for head := idx.nTerms; head < len(idx.symbols); head++ {
+		for _, term := range sortedTerms(t.cells[head]) {
+			prods := t.cells[head][term]
+			if len(prods) < 2 {
+				continue
+			}
+			kind := FIRST_FIRST
+			if byFollow[head][term] {
+				kind = FIRST_FOLLOW
+			}
+			products := make([]*Product, len(prods))
+			for i, p := range prods {
+				products[i] = idx.products[p]
+			}
+			t.conflicts = append(t.conflicts, &LLConflict{kind, idx.symbols[head], idx.symbols[term], products})
+		}
+	}
+	return t
+}
+
+// add predicts p for head on term, once even if term is both in the FIRST
+// set of p and, p being nullable, in the FOLLOW set of head.
+func (t *llTable) add(head, term, p int) {
+	for _, q := range t.cells[head][term] {
+		if q == p {
+			return
+		}
+	}
+	t.cells[head][term] = append(t.cells[head][term], p)
+}
+
+// LL1Conflicts returns the conflicts of the LL(1) table of g, ordered by
+// non-terminal and lookahead. g is LL(1) if there are none.
+func (g *G) LL1Conflicts() []*LLConflict {
+	return buildLLTable(g.analysis()).conflicts
+}
+
+// LLParser is a table-driven predictive parser. It expands the leftmost
+// non-terminal by the one product its LL(1) table predicts for the next
+// token, and runs the Callback of every product once its body is parsed.
+type LLParser struct {
+	g     *G
+	lex   Lexer
+	table *llTable
+}
+
+// NewLLParser builds the LL(1) table of g. It fails with a ValidationError
+// if g has errors, or with an LLConflictError if g is not LL(1), e.g.
+// because it is left-recursive.
+func NewLLParser(g *G, lex Lexer) (*LLParser, error) {
+	if err := validate(g.Validate()); err != nil {
+		return nil, err
+	}
+	table := buildLLTable(g.analysis())
+	if len(table.conflicts) > 0 {
+		return nil, &LLConflictError{table.conflicts}
+	}
+	return &LLParser{g, lex, table}, nil
+}
+
+// llEntry is an entry of the prediction stack: a symbol to match or
+// expand, or, for reduce, the product whose body has just been parsed.
+type llEntry struct {
+	sym    int
+	prod   int
+	reduce bool
+}
+
+func (parser *LLParser) Parse(w string) (interface{}, error) {
+	idx := parser.table.idx
+	start, ok := idx.ids[parser.g.StartSymbol]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("no products for start symbol %v", parser.g.StartSymbol))
+	}
+	terminals := parser.g.GetTerminals()
+	lex := sessionLexer(parser.lex)
+	lex.GetReader(w)
+
+	var token Token
+	var a int
+	next := func() error {
+		token = lex.GetNextToken()
+		sym, ok := idx.ids[getTerminalFrom(terminals, token.Name)]
+		if !ok {
+			return errors.New(fmt.Sprintf("unknown token: %v\n", token))
+		}
+		a = sym
+		return nil
+	}
+	syntaxError := func(expects []*ProductElem) error {
+		line, column := tokenPosition(lex, token)
+		return errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. expects one of %v, but actual '%v'\n", line, column, expects, token))
+	}
+	if err := next(); err != nil {
+		return nil, err
+	}
+
+	stack := []llEntry{{start, 0, false}}
+	values := []interface{}{}
+	spans := []Span{}
+	for len(stack) > 0 {
+		top := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch {
+		case top.reduce:
+			prod := idx.products[top.prod]
+			n := len(idx.bodies[top.prod])
+			popped := values[len(values)-n:]
+			values = values[:len(values)-n]
+			span := emptySpan(token.Span)
+			if n > 0 {
+				span = joinSpans(spans[len(spans)-n], spans[len(spans)-1])
+			}
+			spans = append(spans[:len(spans)-n], span)
+			body := []interface{}{}
+			for _, elem := range prod.Body {
+				if elem == EmptyElem {
+					body = append(body, nil)
+				} else {
+					body = append(body, popped[0])
+					popped = popped[1:]
+				}
+			}
+			values = append(values, reduceValue(parser.g, prod, body, span))
+		case idx.isTerminal(top.sym):
+			if top.sym != a {
+				return nil, syntaxError([]*ProductElem{idx.symbols[top.sym]})
+			}
+			values = append(values, token)
+			spans = append(spans, token.Span)
+			if err := next(); err != nil {
+				return nil, err
+			}
+		default:
+			prods, ok := parser.table.cells[top.sym][a]
+			if !ok {
+				expects := []*ProductElem{}
+				for _, term := range sortedTerms(parser.table.cells[top.sym]) {
+					expects = append(expects, idx.symbols[term])
+				}
+				return nil, syntaxError(expects)
+			}
+			p := prods[0]
+			stack = append(stack, llEntry{top.sym, p, true})
+			body := idx.bodies[p]
+			for i := len(body) - 1; i >= 0; i-- {
+				stack = append(stack, llEntry{body[i], 0, false})
+			}
+		}
+	}
+	if a != idx.ids[eot] {
+		return nil, syntaxError([]*ProductElem{eot})
+	}
+	return values[0], nil
+}
+
+func sortedTerms(m map[int][]int) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
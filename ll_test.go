@@ -0,0 +1,77 @@
+package gdpgen
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLL1Conflicts(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		// want lists the conflicts as kind, non-terminal and lookahead
+		want []string
+	}{
+		{"LL(1)", `s : "(" l ")" | "x" ; l : s t ; t : "," s t | %empty ;`, nil},
+		{"left recursion", `%token num /\d+/
+e : e "+" num | num ;`, []string{"FIRST/FIRST e num"}},
+		{"common prefix", `s : "a" "b" | "a" "c" ;`, []string{"FIRST/FIRST s a"}},
+		{"nullable before follow", `s : a "x" ; a : "x" | %empty ;`, []string{"FIRST/FOLLOW a x"}},
+		{"two nullable", `s : a "x" ; a : b | %empty ; b : %empty | "y" ;`, []string{"FIRST/FOLLOW a x"}},
+		{"nullable with first in follow", `s : a "t" ; a : b ; b : "t" | %empty ;`, []string{"FIRST/FOLLOW b t"}},
+	}
+	for _, tc := range tests {
+		g, err := ParseGrammar(strings.NewReader(tc.src))
+		if err != nil {
+			t.Errorf("%v: %v", tc.name, err)
+			continue
+		}
+		got := []string{}
+		for _, c := range g.LL1Conflicts() {
+			got = append(got, c.Kind.String()+" "+c.NonTerminal.Sig+" "+c.Lookahead.Sig)
+		}
+		if strings.Join(got, "; ") != strings.Join(tc.want, "; ") {
+			t.Errorf("%v: conflicts %v, want %v", tc.name, got, tc.want)
+		}
+
+		_, err = NewLLParser(g, g.NewLexer())
+		if _, ok := err.(*LLConflictError); ok != (len(tc.want) > 0) {
+			t.Errorf("%v: NewLLParser = %v", tc.name, err)
+		}
+	}
+}
+
+func TestLLParser(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(`s : "(" l ")" | "x" ; l : s t ; t : "," s t | %empty ;`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := NewLLParser(g, g.NewLexer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"x", "[x]", true},
+		{"( x )", "[( [[x] [nil]] )]", true},
+		{"( x , ( x ) )", "[( [[x] [, [( [[x] [nil]] )] [nil]]] )]", true},
+		{"( )", "", false},
+		{"( x x )", "", false},
+		{"x x", "", false},
+	}
+	for _, tc := range tests {
+		value, err := parser.Parse(tc.input)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want a syntax error", tc.input, shape(value))
+			}
+			continue
+		}
+		if err != nil || shape(value) != tc.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v", tc.input, shape(value), err, tc.want)
+		}
+	}
+}
@@ -9,31 +9,32 @@ import (
 
 var logger = log.New(os.Stderr, "[Parser] ", 0)
 
+// Parser is an LR parser: the tables of a CompiledGrammar driven over the
+// tokens of a Lexer.
 type Parser struct {
-	augG        *G
-	lex         Lexer
-	actionTable *actionTable
-	gotoTable   *goToTable
+	*CompiledGrammar
+	lex      Lexer
+	newLexer func() Lexer
+	tracer   Tracer
 }
 
+// NewParser builds a canonical LR(1) parser for g. It panics if g has
+// errors found by G.Validate, and logs the warnings and the conflicts.
 func NewParser(g *G, lex Lexer) *Parser {
-	augment(g)
-	parser := &Parser{
-		g,
-		lex,
-		newActionTable(),
-		newGoToTable(),
+	diagnostics := g.Validate()
+	if err := validate(diagnostics); err != nil {
+		panic(err)
 	}
-	parser.constructParsingTable()
-
-	return parser
-}
-
-type ast struct {
-	name  string
-	value interface{}
-	lhs   *ast
-	rhs   *ast
+	for _, d := range diagnostics {
+		logger.Printf("grammar %v", d)
+	}
+	cg := compile(g, CANONICAL_LR1)
+	for _, c := range cg.Conflicts() {
+		if !c.ByPrecedence {
+			logger.Printf("action conflict: %v", c)
+		}
+	}
+	return cg.NewParser(lex)
 }
 
 var augStartElem = &ProductElem{
@@ -70,7 +71,7 @@ func (action *action) String() string {
 	case errorAction:
 		return fmt.Sprintf("<<errorAction>>")
 	}
-	return fmt.Sprintf("%v", action)
+	return fmt.Sprintf("<<action %d>>", action.op)
 }
 
 func newShiftAction(state int) *action {
@@ -98,37 +99,178 @@ func newErrorAction() *action {
 }
 
 type actionTable struct {
-	states map[int]map[*ProductElem]*action
+	states     map[int]map[*ProductElem]*action
+	candidates map[int]map[*ProductElem][]*action
+	conflicts  []*Conflict
+	ambiguous  map[int]map[*ProductElem]bool
 }
 
 func newActionTable() *actionTable {
 	return &actionTable{
 		make(map[int]map[*ProductElem]*action),
+		make(map[int]map[*ProductElem][]*action),
+		[]*Conflict{},
+		make(map[int]map[*ProductElem]bool),
 	}
 }
 
+// set records act as a candidate for the cell; the final action of every
+// cell is chosen by resolve once all candidates are known.
 func (t *actionTable) set(state int, term *ProductElem, act *action) {
 	// if has not register state, create
-	if _, ok := t.states[state]; !ok {
-		t.states[state] = make(map[*ProductElem]*action)
+	if _, ok := t.candidates[state]; !ok {
+		t.candidates[state] = make(map[*ProductElem][]*action)
 	}
 
-	// shiftAction/reduceAction handling (shiftAction is higer priority)
-	if val, ok := t.states[state][term]; ok {
-		switch t.states[state][term].op {
-		case reduceAction:
-			if act.op == shiftAction {
-				logger.Printf("action conflict: %v %v, %v, %v", val, state, term, act)
-				t.states[state][term] = act
+	for _, val := range t.candidates[state][term] {
+		if val.op == act.op && val.state == act.state && val.prod == act.prod {
+			return
+		}
+	}
+	t.candidates[state][term] = append(t.candidates[state][term], act)
+}
+
+// resolve chooses one action per cell and records every conflict it had to
+// decide. Reduce/reduce conflicts go to the product declared first in g.
+// Shift/reduce conflicts are decided by the precedence of the terminal and
+// of the product when both have one, and in favour of shift otherwise.
+func (t *actionTable) resolve(g *G) {
+	t.conflicts = []*Conflict{}
+	for state, cells := range t.candidates {
+		t.states[state] = make(map[*ProductElem]*action)
+		for term, acts := range cells {
+			var shift, reduce, accept *action
+			reduces := []*Product{}
+			for _, act := range acts {
+				switch act.op {
+				case shiftAction:
+					shift = act
+				case reduceAction:
+					reduces = append(reduces, act.prod)
+					if reduce == nil || productIndex(g, act.prod) < productIndex(g, reduce.prod) {
+						reduce = act
+					}
+				case acceptAction:
+					accept = act
+				}
+			}
+
+			if len(reduces) > 1 {
+				t.markAmbiguous(state, term)
+				sortProducts(g, reduces)
+				t.conflicts = append(t.conflicts, &Conflict{
+					Kind:       REDUCE_REDUCE,
+					State:      state,
+					Lookahead:  term,
+					ShiftTo:    -1,
+					Products:   reduces,
+					Resolution: RESOLVED_REDUCE,
+					Chosen:     reduce.prod,
+					Reason:     "reduce by the product declared first",
+				})
+			}
+
+			switch {
+			case accept != nil:
+				t.states[state][term] = accept
+				if reduce != nil {
+					// only a cyclic grammar can reduce where it accepts
+					t.markAmbiguous(state, term)
+				}
+			case shift == nil:
+				t.states[state][term] = reduce
+			case reduce == nil:
+				t.states[state][term] = shift
+			default:
+				conflict := resolveShiftReduce(g, state, term, shift, reduce)
+				t.conflicts = append(t.conflicts, conflict)
+				if !conflict.ByPrecedence {
+					t.markAmbiguous(state, term)
+				}
+				switch conflict.Resolution {
+				case RESOLVED_SHIFT:
+					t.states[state][term] = shift
+				case RESOLVED_REDUCE:
+					t.states[state][term] = reduce
+				default:
+					t.states[state][term] = newErrorAction()
+				}
 			}
 		}
-	} else {
-		t.states[state][term] = act
 	}
+	sortConflicts(t.conflicts)
+}
+
+func (t *actionTable) markAmbiguous(state int, term *ProductElem) {
+	if _, ok := t.ambiguous[state]; !ok {
+		t.ambiguous[state] = make(map[*ProductElem]bool)
+	}
+	t.ambiguous[state][term] = true
+}
+
+func resolveShiftReduce(g *G, state int, term *ProductElem, shift, reduce *action) *Conflict {
+	conflict := &Conflict{
+		Kind:      SHIFT_REDUCE,
+		State:     state,
+		Lookahead: term,
+		ShiftTo:   shift.state,
+		Products:  []*Product{reduce.prod},
+		Chosen:    reduce.prod,
+	}
+	termPrec, termOk := g.precs[term]
+	prodPrec, prodOk := g.productPrecedence(reduce.prod)
+	if !termOk || !prodOk {
+		conflict.Resolution = RESOLVED_SHIFT
+		conflict.Reason = "shift by default"
+		return conflict
+	}
+
+	conflict.ByPrecedence = true
+	switch {
+	case prodPrec.level > termPrec.level:
+		conflict.Resolution = RESOLVED_REDUCE
+		conflict.Reason = fmt.Sprintf("product precedence %v is higher than %v of %v", prodPrec.level, termPrec.level, term.Sig)
+		return conflict
+	case prodPrec.level < termPrec.level:
+		conflict.Resolution = RESOLVED_SHIFT
+		conflict.Reason = fmt.Sprintf("precedence %v of %v is higher than product precedence %v", termPrec.level, term.Sig, prodPrec.level)
+		return conflict
+	}
+	switch termPrec.assoc {
+	case LEFT_ASSOC:
+		conflict.Resolution = RESOLVED_REDUCE
+	case RIGHT_ASSOC:
+		conflict.Resolution = RESOLVED_SHIFT
+	default:
+		conflict.Resolution = RESOLVED_ERROR
+	}
+	conflict.Reason = fmt.Sprintf("%v is %%%v", term.Sig, termPrec.assoc)
+	return conflict
+}
+
+func productIndex(g *G, prod *Product) int {
+	for i, p := range g.Products {
+		if p == prod {
+			return i
+		}
+	}
+	return len(g.Products)
+}
+
+// all returns every action of a cell: the candidates of a conflict that
+// precedence did not decide, otherwise the action chosen by resolve.
+func (t *actionTable) all(state int, term *ProductElem) []*action {
+	if t.ambiguous[state][term] {
+		return t.candidates[state][term]
+	}
+	if act, ok := t.states[state][term]; ok && act.op != errorAction {
+		return []*action{act}
+	}
+	return nil
 }
 
 func (t *actionTable) get(state int, term *ProductElem) (*action, error) {
-	if act, ok := t.states[state][term]; ok {
+	if act, ok := t.states[state][term]; ok && act.op != errorAction {
 		return act, nil
 	} else {
 		return newErrorAction(), errors.New(fmt.Sprintf("invalid syntax"))
@@ -190,42 +332,72 @@ func (self *semaStack) String() string {
 	return fmt.Sprintf("%v", self.stack)
 }
 
+// Parse parses w and returns the value computed by the Callbacks. It is safe
+// for concurrent use if the parser has a lexer factory or a lexer that
+// implements LexerCloner, as RegexLexer does.
 func (parser *Parser) Parse(w string) (interface{}, error) {
-	parser.lex.GetReader(w)
+	return parser.parse(w, false)
+}
+
+// ParseTree parses w like Parse, but returns its concrete syntax tree
+// instead of running the Callbacks.
+func (parser *Parser) ParseTree(w string) (*Node, error) {
+	value, err := parser.parse(w, true)
+	if err != nil {
+		return nil, err
+	}
+	return value.(*Node), nil
+}
+
+// parse runs the LR driver over w. The semantic values are the results of
+// the Callbacks, or, if tree is set, the nodes of the syntax tree.
+func (parser *Parser) parse(w string, tree bool) (interface{}, error) {
+	lex := parser.lexer()
+	lex.GetReader(w)
 	var token Token
 	var a *ProductElem
 	stack := []int{0}
 	semaStack := newSemaStack()
+	spans := []Span{}
 	terminals := parser.augG.GetTerminals()
-	token = parser.lex.GetNextToken()
+	token = lex.GetNextToken()
 	a = getTerminalFrom(terminals, token.Name)
 	if a == nil {
-		return nil, errors.New(fmt.Sprintf("unknown token: %v\n", token))
+		return nil, parser.fail(0, token, errors.New(fmt.Sprintf("unknown token: %v\n", token)), stack, semaStack)
 	}
 	for {
 		s := stack[len(stack)-1]
 		act, err := parser.actionTable.get(s, a)
-		// logger.Printf("s: %v, a: %v, act: %v, state: %v\n", s, a, act, stack)
 		if err != nil {
-			line, column := parser.lex.GetCurrentPosition()
+			line, column := tokenPosition(lex, token)
 			expects := getCandidatesFromActionTable(parser, s)
-			return nil, errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. expects one of %v, but actual '%v'\n", line, column-len(token.Value), expects, token))
+			return nil, parser.fail(s, token, errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. expects one of %v, but actual '%v'\n", line, column, expects, token)), stack, semaStack)
 		}
 		switch act.op {
 		case shiftAction:
 			stack = append(stack, act.state)
-			semaStack.push(token)
-			token = parser.lex.GetNextToken()
+			if tree {
+				semaStack.push(newLeaf(a, token))
+			} else {
+				semaStack.push(token)
+			}
+			spans = append(spans, token.Span)
+			if parser.tracer != nil {
+				parser.tracer.OnShift(act.state, token, stack, semaStack.stack)
+			}
+			token = lex.GetNextToken()
 			a = getTerminalFrom(terminals, token.Name)
 			if a == nil {
-				return nil, errors.New(fmt.Sprintf("unknown token: %v\n", token))
+				return nil, parser.fail(act.state, token, errors.New(fmt.Sprintf("unknown token: %v\n", token)), stack, semaStack)
 			}
 		case reduceAction:
 			prod := act.prod
 			poppedSemas := []interface{}{}
+			n := 0
 			for _, elem := range prod.Body {
 				if elem != EmptyElem {
 					_, stack = popStack(stack)
+					n++
 
 					// semantic handle
 					poppedSemas = append(poppedSemas, semaStack.pop())
@@ -236,233 +408,104 @@ func (parser *Parser) Parse(w string) (interface{}, error) {
 			t := stack[len(stack)-1]
 			goTo, goToErr := parser.gotoTable.get(t, prod.Head)
 			if goToErr != nil {
-				fmt.Printf("%v\n", goToErr)
-				line, column := parser.lex.GetCurrentPosition()
-				return nil, errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. token: %v\n", line, column-len(token.Value), token))
+				line, column := tokenPosition(lex, token)
+				return nil, parser.fail(t, token, errors.New(fmt.Sprintf("invalid syntax at line:%v, column:%v. token: %v\n", line, column, token)), stack, semaStack)
 			}
-			stack = append(stack, goTo)
 
-			// semantic callback
-			if prod.Callback != nil {
-				reversed := make([]interface{}, len(poppedSemas))
-				for i, sema := range poppedSemas {
-					reversed[len(reversed)-1-i] = sema
-				}
+			// the span of an empty product is where the lookahead starts
+			span := emptySpan(token.Span)
+			if n > 0 {
+				span = joinSpans(spans[len(spans)-n], spans[len(spans)-1])
+			}
+			spans = append(spans[:len(spans)-n], span)
 
-				reduced := prod.Callback(reversed)
-				semaStack.push(reduced)
+			// semantic callback
+			reversed := make([]interface{}, len(poppedSemas))
+			for i, sema := range poppedSemas {
+				reversed[len(reversed)-1-i] = sema
+			}
+			if tree {
+				semaStack.push(parser.newNode(prod, reversed, span))
 			} else {
-				semaStack.push(poppedSemas)
+				semaStack.push(reduceValue(parser.augG, prod, reversed, span))
+			}
+			if parser.tracer != nil {
+				parser.tracer.OnReduce(prod, s, stack, semaStack.stack)
+			}
+
+			stack = append(stack, goTo)
+			if parser.tracer != nil {
+				parser.tracer.OnGoto(t, prod.Head, goTo, stack, semaStack.stack)
 			}
 		case acceptAction:
+			var value interface{} = true
 			if 0 < len(semaStack.stack) {
-				return semaStack.pop(), nil
-			} else {
-				return true, nil
+				value = semaStack.pop()
 			}
+			if parser.tracer != nil {
+				parser.tracer.OnAccept(value, stack, semaStack.stack)
+			}
+			return value, nil
 		case errorAction:
 			panic("invalid syntax")
 		}
 	}
 }
 
-func getCandidatesFromActionTable(parser *Parser, state int) []*ProductElem {
-	candidates := []*ProductElem{}
-	stateMap := parser.actionTable.states[state]
-	for i := range stateMap {
-		candidates = append(candidates, i)
-	}
-	return candidates
-}
-
-func popStack(stack []int) (int, []int) {
-	if len(stack) < 2 {
-		return stack[0], []int{}
+// fail reports err to the tracer and returns it.
+func (parser *Parser) fail(state int, token Token, err error, stack []int, semaStack *semaStack) error {
+	if parser.tracer != nil {
+		parser.tracer.OnError(state, token, err, stack, semaStack.stack)
 	}
-	return stack[len(stack)-1], stack[:len(stack)-1]
-}
-
-type item struct {
-	head      *ProductElem
-	body      []*ProductElem
-	position  int
-	lookahead *ProductElem
-}
-
-func (self item) String() string {
-	return fmt.Sprintf("{%v -> %v,%v %v}",
-		self.head, self.body, self.lookahead.Sig, self.position)
-}
-
-type setOfItems struct {
-	items []*item
-}
-
-func (itemSet setOfItems) String() string {
-	return fmt.Sprintf("%v", itemSet.items)
+	return err
 }
 
-func newSetOfItems() *setOfItems {
-	return &setOfItems{}
-}
-
-func newSetOfItemsFrom(items ...*item) *setOfItems {
-	return &setOfItems{items}
-}
-
-func (items *setOfItems) Has(item *item) bool {
-	for _, val := range items.items {
-		if val.head == item.head &&
-			CompareProductElem(val.body, item.body) &&
-			val.position == item.position &&
-			val.lookahead == item.lookahead {
-			return true
-		}
+// lexer returns the lexer for one parse: a new one from the factory, a
+// clone of the parser's lexer, or, if it cannot be cloned, the lexer itself.
+func (parser *Parser) lexer() Lexer {
+	if parser.newLexer != nil {
+		return parser.newLexer()
 	}
-	return false
-}
-
-func (items *setOfItems) Values() []*item {
-	return items.items
+	return sessionLexer(parser.lex)
 }
 
-func (items *setOfItems) Add(item *item) {
-	if !items.Has(item) {
-		items.items = append(items.items, item)
+func sessionLexer(lex Lexer) Lexer {
+	if cloner, ok := lex.(LexerCloner); ok {
+		return cloner.Clone()
 	}
+	return lex
 }
 
-func (a *setOfItems) Equals(b *setOfItems) bool {
-	if len(a.items) != len(b.items) {
-		return false
-	}
-
-	equals := true
-	for _, item := range b.items {
-		if !a.Has(item) {
-			equals = false
-			break
-		}
-	}
-	return equals
-}
-
-// Add item
-func (parser *Parser) closure(items *setOfItems) {
-	changed := true
-	for changed {
-		changed = false
-		for _, e := range items.Values() {
-			_, nonTerm, follow, lookAhead := match(e)
-			for _, product := range parser.augG.GetProductsOf(nonTerm) {
-				first := parser.firstAll(append(follow, lookAhead))
-				for _, terminal := range first {
-					newItem := &item{
-						nonTerm,
-						product.Body,
-						0,
-						terminal}
-					if !items.Has(newItem) {
-						items.Add(newItem)
-						changed = true
-					}
-				}
-			}
-		}
+// reduceValue returns the semantic value of a reduction by prod of g, given
+// the values of its body in order and the span they cover: the result of
+// the span callback set by G.SetSpanCallback or of the Callback, or the
+// values themselves when there is none.
+func reduceValue(g *G, prod *Product, values []interface{}, span Span) interface{} {
+	if m, ok := g.meta[prod]; ok && m.spanCallback != nil {
+		return m.spanCallback(values, span)
 	}
-}
-
-// Create new setOfItems
-func (parser Parser) goTo(items *setOfItems, symbol *ProductElem) *setOfItems {
-	itemsRight := newSetOfItems()
-	for _, e := range items.Values() {
-		beforeDot, afterDot, tail, lookahead := match(e)
-		if afterDot == EmptyElem {
-			continue
-		}
-		if afterDot == symbol {
-			itemsRight.Add(&item{
-				e.head,
-				append(append(beforeDot, afterDot), tail...),
-				e.position + 1,
-				lookahead})
-		}
+	if prod.Callback != nil {
+		return prod.Callback(values)
 	}
-	parser.closure(itemsRight)
-	return itemsRight
+	return values
 }
 
-func (parser *Parser) items() []*setOfItems {
-	item := &item{
-		augStartElem,
-		[]*ProductElem{parser.augG.StartSymbol},
-		0,
-		eot}
-	var collections []*setOfItems
-	items := newSetOfItemsFrom(item)
-	parser.closure(items)
-	collections = []*setOfItems{items}
-
-	symbols := parser.augG.GetSymbolSet()
-	var changed = true
-	for changed {
-		changed = false
-		for _, items := range collections {
-			for _, symbol := range symbols {
-				goToItems := parser.goTo(items, symbol)
-				if len(goToItems.items) > 0 && !has(collections, goToItems) {
-					collections = append(collections, goToItems)
-					changed = true
-				}
-			}
+func getCandidatesFromActionTable(parser *Parser, state int) []*ProductElem {
+	candidates := []*ProductElem{}
+	stateMap := parser.actionTable.states[state]
+	for i, act := range stateMap {
+		if act.op != errorAction {
+			candidates = append(candidates, i)
 		}
 	}
-
-	return collections
+	return candidates
 }
 
-func (parser *Parser) constructParsingTable() {
-	// construct collection of sets of LR(1) items
-	collections := parser.items()
-
-	// construct action table
-	for i, items := range collections {
-		for _, item := range items.items {
-			beforeDot, afterDot, _, lookahead := match(item)
-			if afterDot != EmptyElem && afterDot.IsTerminal {
-				nextStateItem := parser.goTo(items, afterDot)
-				for j, testItems := range collections {
-					if nextStateItem.Equals(testItems) {
-						parser.actionTable.set(i, afterDot, newShiftAction(j))
-					}
-				}
-			} else if item.head != augStartElem {
-				if afterDot == EmptyElem {
-					prod := parser.augG.GetProductOf(item.head, beforeDot)
-					parser.actionTable.set(i, lookahead, newReduceAction(prod))
-				}
-			} else if item.head == augStartElem &&
-				afterDot == EmptyElem &&
-				lookahead == eot {
-				parser.actionTable.set(i, eot, newAcceptAction())
-			}
-		}
-	}
-
-	// construct goto table
-	nonTerminals := parser.augG.GetNonTerminals()
-	for i, items := range collections {
-		for _, nonTerm := range nonTerminals {
-			for j, testItems := range collections {
-				if parser.goTo(items, nonTerm).Equals(testItems) {
-					parser.gotoTable.set(i, nonTerm, j)
-				}
-			}
-		}
+func popStack(stack []int) (int, []int) {
+	if len(stack) < 2 {
+		return stack[0], []int{}
 	}
-
-	// dumpActionTable(parser.actionTable)
-	// dumpGoToTable(parser.gotoTable)
+	return stack[len(stack)-1], stack[:len(stack)-1]
 }
 
 func dumpActionTable(actTable *actionTable) {
@@ -494,147 +537,6 @@ func dumpGoToTable(goToTable *goToTable) {
 	}
 }
 
-func (parser *Parser) first(elem *ProductElem) []*ProductElem {
-	firstSet := []*ProductElem{}
-	if elem.IsTerminal {
-		return []*ProductElem{elem}
-	}
-	for _, productBody := range parser.augG.GetProductBodySet(elem) {
-		if len(productBody) == 1 && productBody[0] == EmptyElem {
-			firstSet = append(firstSet, EmptyElem)
-			continue
-		}
-		var recentSet []*ProductElem
-		var emptyCount = 0
-		for i := range productBody {
-			recentSet = parser.first(productBody[i])
-			firstSet = append(firstSet, recentSet...)
-			if !hasElem(recentSet, EmptyElem) {
-				break
-			} else {
-				emptyCount++
-			}
-		}
-		if emptyCount == len(productBody) {
-			firstSet = append(firstSet, EmptyElem)
-		}
-	}
-	dupRemoved := removeDup(firstSet)
-
-	return dupRemoved
-}
-
-func removeDup(array []*ProductElem) []*ProductElem {
-	m := make(map[*ProductElem]struct{})
-	for _, elem := range array {
-		m[elem] = struct{}{}
-	}
-	array2 := []*ProductElem{}
-	for key := range m {
-		array2 = append(array2, key)
-	}
-	return array2
-}
-
-func (parser *Parser) nullable(elem *ProductElem) bool {
-	if elem == EmptyElem {
-		return true
-	}
-
-	productBodySet := parser.augG.GetProductBodySet(elem)
-	for _, productBody := range productBodySet {
-		nullable := true
-		for _, productBodyElem := range productBody {
-			if !parser.nullable(productBodyElem) {
-				nullable = false
-				break
-			}
-		}
-		if nullable {
-			return true
-		}
-	}
-
-	return false
-}
-
-func (parser *Parser) firstAll(elems []*ProductElem) []*ProductElem {
-	if len(elems) < 1 {
-		panic("invalid argument")
-	}
-
-	allFirstSet := []*ProductElem{}
-	for i := 0; i < len(elems); i++ {
-		firstSet := parser.first(elems[i])
-		allFirstSet = append(allFirstSet, nonEmptySet(firstSet)...)
-		if !existsEmpty(firstSet) {
-			break
-		}
-	}
-	return allFirstSet
-}
-
-func existsEmpty(symbols []*ProductElem) bool {
-	for _, symbol := range symbols {
-		if symbol == EmptyElem {
-			return true
-		}
-	}
-	return false
-}
-
-func nonEmptySet(elems []*ProductElem) []*ProductElem {
-	arr := []*ProductElem{}
-	for _, elem := range elems {
-		if elem == EmptyElem {
-			continue
-		}
-		arr = append(arr, elem)
-	}
-	return arr
-}
-
-// match to [A → α·Bβ,a]
-func match(item *item) ([]*ProductElem, *ProductElem, []*ProductElem, *ProductElem) {
-	var beforeDot []*ProductElem
-	if item.position == 0 {
-		beforeDot = []*ProductElem{}
-	} else {
-		beforeDot = item.body[:item.position]
-	}
-
-	var afterDot *ProductElem
-	if item.position < len(item.body) {
-		afterDot = item.body[item.position]
-	} else {
-		afterDot = EmptyElem
-	}
-	var tail []*ProductElem
-	if item.position+1 < len(item.body) {
-		tail = item.body[item.position+1:]
-	} else {
-		tail = []*ProductElem{}
-	}
-	return beforeDot, afterDot, tail, item.lookahead
-}
-
-func has(collection []*setOfItems, setOfItems *setOfItems) bool {
-	var allIn bool
-	for _, val := range collection {
-		allIn = true
-		for _, item := range setOfItems.Values() {
-			if !val.Has(item) {
-				allIn = false
-				break
-			}
-		}
-		if allIn {
-			return true
-		}
-	}
-	return false
-}
-
 func hasElem(elems []*ProductElem, test *ProductElem) bool {
 	for _, elem := range elems {
 		if elem == test {
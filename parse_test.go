@@ -0,0 +1,138 @@
+package gdpgen
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestParseConcurrently(t *testing.T) {
+	g := newCalcGrammar(t)
+	cg, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsers := map[string]*Parser{
+		"NewParser":                 NewParser(g, g.NewLexer()),
+		"CompiledGrammar.NewParser": cg.NewParser(g.NewLexer()),
+		"NewParserWithLexerFactory": cg.NewParserWithLexerFactory(g.NewLexer),
+	}
+	inputs := []struct {
+		input string
+		want  int
+	}{
+		{"1 + 1", 2},
+		{"1 + 100", 101},
+		{"8*(1+100)", 808},
+		{"2 * 3 + 4 * 5 - 6", 20},
+		{"100 / (2 + 3) / 4", 5},
+	}
+
+	for name, parser := range parsers {
+		parser := parser
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for round := 0; round < 20; round++ {
+						for _, tc := range inputs {
+							value, err := parser.Parse(tc.input)
+							if err != nil || value != tc.want {
+								t.Errorf("Parse(%q) = %v, %v, want %v", tc.input, value, err, tc.want)
+							}
+							tree, err := parser.ParseTree(tc.input)
+							if err != nil || tree.Start != 0 || tree.End != len(tc.input) {
+								t.Errorf("ParseTree(%q) = %v, %v", tc.input, tree, err)
+							}
+							forest, err := parser.ParseForest(tc.input)
+							if err != nil {
+								t.Errorf("ParseForest(%q): %v", tc.input, err)
+								continue
+							}
+							if value, err := forest.Evaluate(nil); err != nil || value != tc.want {
+								t.Errorf("ParseForest(%q).Evaluate = %v, %v, want %v", tc.input, value, err, tc.want)
+							}
+						}
+					}
+				}()
+			}
+			wg.Wait()
+		})
+	}
+}
+
+// spanValues makes every product of g yield its head, span and the values
+// of its body, so that a value shows how the input was parsed.
+func spanValues(g *G) {
+	for _, p := range g.Products {
+		head := p.Head.Sig
+		g.SetSpanCallback(p, func(values []interface{}, span Span) interface{} {
+			parts := []string{}
+			for _, value := range values {
+				switch v := value.(type) {
+				case Token:
+					parts = append(parts, v.Value)
+				case string:
+					parts = append(parts, v)
+				}
+			}
+			return fmt.Sprintf("%v[%v:%v %v:%v-%v:%v](%v)", head, span.Start, span.End,
+				span.Line, span.Column, span.EndLine, span.EndColumn, strings.Join(parts, " "))
+		})
+	}
+}
+
+func TestBackends(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(`
+%token id /[a-zé]+/
+s : "(" l ")" | id ;
+l : s t ;
+t : "," s t | %empty ;
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spanValues(g)
+	cg, err := Compile(g, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ll, err := NewLLParser(g, g.NewLexer())
+	if err != nil {
+		t.Fatal(err)
+	}
+	backends := map[string]func(string) (interface{}, error){
+		"LR": cg.NewParser(g.NewLexer()).Parse,
+		"GLR": func(input string) (interface{}, error) {
+			forest, err := cg.NewParser(g.NewLexer()).ParseForest(input)
+			if err != nil {
+				return nil, err
+			}
+			return forest.Evaluate(nil)
+		},
+		"Earley": NewEarleyParser(g, g.NewLexer()).Parse,
+		"LL":     ll.Parse,
+	}
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x", "s[0:1 1:1-1:2](x)"},
+		{"(é)", "s[0:4 1:1-1:4](( l[1:3 1:2-1:3](s[1:3 1:2-1:3](é) t[3:3 1:3-1:3]()) ))"},
+		{"(a,\n b)", "s[0:7 1:1-2:4](( l[1:6 1:2-2:3](s[1:2 1:2-1:3](a) t[2:6 1:3-2:3](, s[5:6 2:2-2:3](b) t[6:6 2:3-2:3]())) ))"},
+	}
+	for name, parse := range backends {
+		for _, tc := range tests {
+			value, err := parse(tc.input)
+			if err != nil || value != tc.want {
+				t.Errorf("%v: Parse(%q) = %v, %v, want %v", name, tc.input, value, err, tc.want)
+			}
+		}
+		if _, err := parse("(a b)"); err == nil {
+			t.Errorf("%v: Parse(%q) succeeded", name, "(a b)")
+		}
+	}
+}
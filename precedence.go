@@ -0,0 +1,89 @@
+package gdpgen
+
+const (
+	LEFT_ASSOC Assoc = iota
+	RIGHT_ASSOC
+	NON_ASSOC
+)
+
+// Assoc is the associativity of an operator terminal.
+type Assoc int
+
+func (a Assoc) String() string {
+	switch a {
+	case LEFT_ASSOC:
+		return "left"
+	case RIGHT_ASSOC:
+		return "right"
+	case NON_ASSOC:
+		return "nonassoc"
+	}
+	return "unknown"
+}
+
+type precedence struct {
+	level int
+	assoc Assoc
+}
+
+// Left declares terms as left-associative operators. As with yacc's %left,
+// every call opens a new precedence level that binds tighter than the
+// levels declared before it.
+func (g *G) Left(terms ...*ProductElem) {
+	g.declarePrecedence(LEFT_ASSOC, terms)
+}
+
+// Right declares terms as right-associative operators (%right).
+func (g *G) Right(terms ...*ProductElem) {
+	g.declarePrecedence(RIGHT_ASSOC, terms)
+}
+
+// NonAssoc declares terms as non-associative operators (%nonassoc), so that
+// "a op b op c" is a syntax error.
+func (g *G) NonAssoc(terms ...*ProductElem) {
+	g.declarePrecedence(NON_ASSOC, terms)
+}
+
+func (g *G) declarePrecedence(assoc Assoc, terms []*ProductElem) {
+	if g.precs == nil {
+		g.precs = make(map[*ProductElem]precedence)
+	}
+	level := 1
+	for _, prec := range g.precs {
+		if prec.level >= level {
+			level = prec.level + 1
+		}
+	}
+	for _, term := range terms {
+		g.precs[g.table().intern(term)] = precedence{level, assoc}
+	}
+}
+
+// SetPrec gives p the precedence of term (%prec) instead of the precedence of
+// the last terminal of its body.
+func (g *G) SetPrec(p *Product, term *ProductElem) {
+	g.metaOf(p).prec = g.table().intern(term)
+}
+
+// Precedence returns the precedence level and associativity of term.
+// Higher levels bind tighter; ok is false if term has no precedence.
+func (g *G) Precedence(term *ProductElem) (level int, assoc Assoc, ok bool) {
+	prec, ok := g.precs[g.table().lookup(term)]
+	return prec.level, prec.assoc, ok
+}
+
+// productPrecedence returns the precedence of p: the one of its %prec
+// terminal if set, otherwise the one of the last terminal in its body.
+func (g *G) productPrecedence(p *Product) (precedence, bool) {
+	if m, ok := g.meta[p]; ok && m.prec != nil {
+		prec, ok := g.precs[m.prec]
+		return prec, ok
+	}
+	for i := len(p.Body) - 1; i >= 0; i-- {
+		if elem := p.Body[i]; elem.IsTerminal && elem != EmptyElem {
+			prec, ok := g.precs[elem]
+			return prec, ok
+		}
+	}
+	return precedence{}, false
+}
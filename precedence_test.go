@@ -0,0 +1,157 @@
+package gdpgen
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+const precGrammar = `
+%token num /\d+/
+%nonassoc "<"
+%left "+" "-"
+%left "*"
+%right "^"
+%left UMINUS
+e : e "<" e {lt} | e "+" e {add} | e "-" e {sub} | e "*" e {mul} | e "^" e {pow}
+  | "-" e %prec UMINUS {neg} | "(" e ")" {paren} | num {num} ;
+`
+
+const calcGrammar = `
+%token number /\d+/
+%left "+" "-"
+%left "*" "/"
+e : e "+" e {add} | e "-" e {sub} | e "*" e {mul} | e "/" e {div} | "(" e ")" {paren} | number {num} ;
+`
+
+// newCalcGrammar returns calcGrammar with callbacks that evaluate it.
+func newCalcGrammar(t testing.TB) *G {
+	g, err := ParseGrammar(strings.NewReader(calcGrammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := map[string]func(a, b int) int{
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+		"mul": func(a, b int) int { return a * b },
+		"div": func(a, b int) int { return a / b },
+	}
+	for label, op := range binary {
+		op := op
+		g.ProductByLabel(label).Callback = func(values []interface{}) interface{} {
+			return op(values[0].(int), values[2].(int))
+		}
+	}
+	g.ProductByLabel("paren").Callback = func(values []interface{}) interface{} {
+		return values[1]
+	}
+	g.ProductByLabel("num").Callback = func(values []interface{}) interface{} {
+		n, _ := strconv.Atoi(values[0].(Token).Value)
+		return n
+	}
+	return g
+}
+
+func TestPrecedence(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(precGrammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := map[string]func(a, b int) int{
+		"add": func(a, b int) int { return a + b },
+		"sub": func(a, b int) int { return a - b },
+		"mul": func(a, b int) int { return a * b },
+		"pow": func(a, b int) int { return int(math.Pow(float64(a), float64(b))) },
+		"lt": func(a, b int) int {
+			if a < b {
+				return 1
+			}
+			return 0
+		},
+	}
+	for label, op := range binary {
+		op := op
+		g.ProductByLabel(label).Callback = func(values []interface{}) interface{} {
+			return op(values[0].(int), values[2].(int))
+		}
+	}
+	g.ProductByLabel("neg").Callback = func(values []interface{}) interface{} {
+		return -values[1].(int)
+	}
+	g.ProductByLabel("paren").Callback = func(values []interface{}) interface{} {
+		return values[1]
+	}
+	g.ProductByLabel("num").Callback = func(values []interface{}) interface{} {
+		n, _ := strconv.Atoi(values[0].(Token).Value)
+		return n
+	}
+
+	cg, err := Compile(g, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cg.Conflicts() {
+		if !c.ByPrecedence {
+			t.Errorf("conflict not resolved by precedence: %v", c)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want  int
+		ok    bool
+	}{
+		{"1 + 2 * 3", 7, true},
+		{"2 * 3 + 4", 10, true},
+		{"10 - 4 - 3", 3, true},
+		{"2 ^ 3 ^ 2", 512, true},
+		{"2 * 3 ^ 2", 18, true},
+		{"-2 + 3", 1, true},
+		{"-2 ^ 2", 4, true},
+		{"1 + 1 < 3", 1, true},
+		{"(1 < 2) < 3", 1, true},
+		{"1 < 2 < 3", 0, false},
+	}
+	parser := cg.NewParser(g.NewLexer())
+	for _, tc := range tests {
+		value, err := parser.Parse(tc.input)
+		if !tc.ok {
+			if err == nil {
+				t.Errorf("Parse(%q) = %v, want a syntax error", tc.input, value)
+			}
+			continue
+		}
+		if err != nil || value != tc.want {
+			t.Errorf("Parse(%q) = %v, %v, want %v", tc.input, value, err, tc.want)
+		}
+	}
+}
+
+func TestPrecedenceLevels(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(precGrammar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		term  string
+		level int
+		assoc Assoc
+	}{
+		{"<", 1, NON_ASSOC},
+		{"+", 2, LEFT_ASSOC},
+		{"-", 2, LEFT_ASSOC},
+		{"*", 3, LEFT_ASSOC},
+		{"^", 4, RIGHT_ASSOC},
+		{"UMINUS", 5, LEFT_ASSOC},
+	}
+	for _, tc := range tests {
+		level, assoc, ok := g.Precedence(g.Terminal(tc.term))
+		if !ok || level != tc.level || assoc != tc.assoc {
+			t.Errorf("Precedence(%q) = %v, %v, %v, want %v, %v", tc.term, level, assoc, ok, tc.level, tc.assoc)
+		}
+	}
+	if _, _, ok := g.Precedence(g.Terminal("num")); ok {
+		t.Errorf("num has a precedence")
+	}
+}
@@ -0,0 +1,271 @@
+package gdpgen
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// report is what WriteReport and WriteHTMLReport render.
+type report struct {
+	Mode         string
+	Rules        []reportRule
+	States       []*reportState
+	ShiftReduce  int
+	ReduceReduce int
+	ByPrecedence int
+}
+
+type reportRule struct {
+	Number int
+	Text   string
+	Label  string
+}
+
+type reportState struct {
+	Number       int
+	Kernel       []string
+	Closure      []string // the items added by closing the kernel
+	Actions      []reportAction
+	Gotos        []reportAction
+	Conflicts    []*Conflict
+	Unresolved   bool
+	Predecessors []int
+}
+
+type reportAction struct {
+	Symbol       string
+	Op           string // shift, reduce, accept, error or goto
+	State        int    // target of shift and goto
+	Rule         int    // product reduced by
+	RuleText     string
+	Conflict     bool
+	ByPrecedence bool // the conflict on Symbol was decided by precedence
+}
+
+func (cg *CompiledGrammar) report() *report {
+	idx := cg.augG.analysis()
+	r := &report{Mode: cg.mode.String()}
+	for i, p := range idx.products {
+		r.Rules = append(r.Rules, reportRule{i, productRule(p), cg.augG.Label(p)})
+	}
+
+	n := cg.numStates()
+	preds := make([]map[int]bool, n)
+	for state := 0; state < n; state++ {
+		preds[state] = make(map[int]bool)
+	}
+	for state := 0; state < n; state++ {
+		s := &reportState{Number: state}
+		r.States = append(r.States, s)
+		if cg.automaton != nil {
+			st := cg.automaton.states[state]
+			for k, it := range st.closure {
+				las := []string{}
+				for _, term := range st.closureLAs[k].members() {
+					las = append(las, idx.symbols[term].Sig)
+				}
+				item := Item{idx.products[it.prod], it.dot, nil}.String() + ", " + strings.Join(las, " ")
+				if k < len(st.kernel) {
+					s.Kernel = append(s.Kernel, item)
+				} else {
+					s.Closure = append(s.Closure, item)
+				}
+			}
+		}
+		for _, term := range idx.symbols[:idx.nTerms] {
+			act, ok := cg.actionTable.states[state][term]
+			if !ok {
+				continue
+			}
+			a := reportAction{term.Sig, "", -1, -1, "", false, false}
+			switch act.op {
+			case shiftAction:
+				a.Op, a.State = "shift", act.state
+				preds[act.state][state] = true
+			case reduceAction:
+				a.Op, a.Rule, a.RuleText = "reduce", idx.prodIDs[act.prod], productRule(act.prod)
+			case acceptAction:
+				a.Op = "accept"
+			case errorAction:
+				a.Op = "error"
+			}
+			s.Actions = append(s.Actions, a)
+		}
+		for _, nonTerm := range idx.symbols[idx.nTerms:] {
+			if to, ok := cg.gotoTable.states[state][nonTerm]; ok {
+				s.Gotos = append(s.Gotos, reportAction{nonTerm.Sig, "goto", to, -1, "", false, false})
+				preds[to][state] = true
+			}
+		}
+	}
+
+	for _, c := range cg.Conflicts() {
+		s := r.States[c.State]
+		s.Conflicts = append(s.Conflicts, c)
+		for i := range s.Actions {
+			if s.Actions[i].Symbol == c.Lookahead.Sig {
+				s.Actions[i].Conflict = true
+				s.Actions[i].ByPrecedence = c.ByPrecedence
+			}
+		}
+		switch {
+		case c.ByPrecedence:
+			r.ByPrecedence++
+		case c.Kind == SHIFT_REDUCE:
+			r.ShiftReduce++
+			s.Unresolved = true
+		default:
+			r.ReduceReduce++
+			s.Unresolved = true
+		}
+	}
+	for state, from := range preds {
+		for pred := 0; pred < n; pred++ {
+			if from[pred] {
+				r.States[state].Predecessors = append(r.States[state].Predecessors, pred)
+			}
+		}
+	}
+	return r
+}
+
+// WriteReport writes a human-readable report of cg in the manner of bison's
+// .output file: the numbered rules, then every state with its kernel and
+// closure items, its actions and gotos, its conflicts with how they were
+// resolved, and the states leading to it.
+func (cg *CompiledGrammar) WriteReport(w io.Writer) error {
+	r := cg.report()
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "Grammar (%v)\n\n", r.Mode)
+	for _, rule := range r.Rules {
+		fmt.Fprintf(b, "%5d  %v", rule.Number, rule.Text)
+		if rule.Label != "" {
+			fmt.Fprintf(b, "  {%v}", rule.Label)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(b, "\nConflicts: %v shift/reduce, %v reduce/reduce, %v resolved by precedence\n",
+		r.ShiftReduce, r.ReduceReduce, r.ByPrecedence)
+
+	for _, s := range r.States {
+		fmt.Fprintf(b, "\n\nState %v", s.Number)
+		if s.Unresolved {
+			b.WriteString(" (conflicts)")
+		}
+		b.WriteString("\n\n")
+		if len(s.Kernel) > 0 {
+			for _, item := range s.Kernel {
+				fmt.Fprintf(b, "    %v\n", item)
+			}
+			for _, item := range s.Closure {
+				fmt.Fprintf(b, "  + %v\n", item)
+			}
+			b.WriteString("\n")
+		}
+		for _, a := range s.Actions {
+			fmt.Fprintf(b, "    %-10v ", a.Symbol)
+			switch a.Op {
+			case "shift":
+				fmt.Fprintf(b, "shift, and go to state %v", a.State)
+			case "reduce":
+				fmt.Fprintf(b, "reduce using rule %v (%v)", a.Rule, a.RuleText)
+			default:
+				b.WriteString(a.Op)
+			}
+			if a.Conflict {
+				b.WriteString("  [conflict]")
+			}
+			b.WriteString("\n")
+		}
+		for _, a := range s.Gotos {
+			fmt.Fprintf(b, "    %-10v go to state %v\n", a.Symbol, a.State)
+		}
+		if len(s.Conflicts) > 0 {
+			b.WriteString("\n")
+			for _, c := range s.Conflicts {
+				fmt.Fprintf(b, "    %v\n", c)
+			}
+		}
+		if len(s.Predecessors) > 0 {
+			preds := make([]string, len(s.Predecessors))
+			for i, p := range s.Predecessors {
+				preds[i] = strconv.Itoa(p)
+			}
+			fmt.Fprintf(b, "\n    reached from states %v\n", strings.Join(preds, ", "))
+		}
+	}
+	return b.Flush()
+}
+
+// WriteHTMLReport writes the report of WriteReport as a standalone HTML
+// page in which states link to each other and conflicts are highlighted.
+func (cg *CompiledGrammar) WriteHTMLReport(w io.Writer) error {
+	return htmlReport.Execute(w, cg.report())
+}
+
+var htmlReport = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>gdpgen report</title>
+<style>
+body { font-family: sans-serif; }
+pre, td { font-family: monospace; }
+td { padding: 0 1em 0 0; }
+.state { border: 1px solid #ccc; margin: 1em 0; padding: 0 1em; }
+.unresolved { background: #f4cccc; }
+.resolved { background: #fff2cc; }
+.closure { color: #666; }
+</style>
+</head>
+<body>
+<h1>Grammar ({{.Mode}})</h1>
+<table>
+{{- range .Rules}}
+<tr><td>{{.Number}}</td><td>{{.Text}}</td><td>{{.Label}}</td></tr>
+{{- end}}
+</table>
+<p>Conflicts: {{.ShiftReduce}} shift/reduce, {{.ReduceReduce}} reduce/reduce, {{.ByPrecedence}} resolved by precedence</p>
+{{- range .States}}
+<div class="state{{if .Unresolved}} unresolved{{else if .Conflicts}} resolved{{end}}" id="state{{.Number}}">
+<h2>State {{.Number}}</h2>
+{{- if .Kernel}}
+<pre>
+{{- range .Kernel}}
+{{.}}
+{{- end}}
+{{- range .Closure}}
+<span class="closure">{{.}}</span>
+{{- end}}
+</pre>
+{{- end}}
+<table>
+{{- range .Actions}}
+<tr{{if .Conflict}} class="{{if .ByPrecedence}}resolved{{else}}unresolved{{end}}"{{end}}><td>{{.Symbol}}</td><td>
+{{- if eq .Op "shift"}}shift, and go to <a href="#state{{.State}}">state {{.State}}</a>
+{{- else if eq .Op "reduce"}}reduce using rule {{.Rule}} ({{.RuleText}})
+{{- else}}{{.Op}}{{end}}</td></tr>
+{{- end}}
+{{- range .Gotos}}
+<tr><td>{{.Symbol}}</td><td>go to <a href="#state{{.State}}">state {{.State}}</a></td></tr>
+{{- end}}
+</table>
+{{- if .Conflicts}}
+<ul>
+{{- range .Conflicts}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .Predecessors}}
+<p>Reached from {{range $i, $p := .Predecessors}}{{if $i}}, {{end}}<a href="#state{{$p}}">state {{$p}}</a>{{end}}</p>
+{{- end}}
+</div>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,95 @@
+package gdpgen
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func compileReported(t *testing.T, src string) *CompiledGrammar {
+	g, err := ParseGrammar(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cg
+}
+
+func TestWriteReport(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"unresolved", "%token num /\\d+/\ne : e \"+\" e | num ;", []string{
+			"Grammar (canonical LR(1))\n\n    0  e -> e + e\n    1  e -> num\n    2  S' -> e\n",
+			"Conflicts: 1 shift/reduce, 0 reduce/reduce, 0 resolved by precedence\n",
+			"State 0\n\n    S' -> • e, $\n  + e -> • e + e, $ +\n  + e -> • num, $ +\n\n    num        shift, and go to state 1\n    e          go to state 2\n",
+			"    $          reduce using rule 1 (e -> num)\n    +          reduce using rule 1 (e -> num)\n\n    reached from states 0, 3\n",
+			"    $          accept\n",
+			"State 4 (conflicts)\n",
+			"    +          shift, and go to state 3  [conflict]\n",
+			"    state 4: shift/reduce conflict on + between [e -> e • + e] and [e -> e + e •, +], resolved as shift to state 3 (shift by default)\n",
+		}},
+		{"precedence", "%token num /\\d+/\n%left \"+\"\ne : e \"+\" e | num ;", []string{
+			"Conflicts: 0 shift/reduce, 0 reduce/reduce, 1 resolved by precedence\n",
+			"State 4\n",
+			"    +          reduce using rule 0 (e -> e + e)  [conflict]\n",
+		}},
+	}
+	for _, tc := range tests {
+		var b bytes.Buffer
+		if err := compileReported(t, tc.src).WriteReport(&b); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%v: report does not contain %q:\n%v", tc.name, want, b.String())
+			}
+		}
+		if n := strings.Count(b.String(), "\nState "); n != 5 {
+			t.Errorf("%v: report has %v states, want 5", tc.name, n)
+		}
+	}
+}
+
+func TestWriteHTMLReport(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []string
+	}{
+		{"unresolved", "%token num /\\d+/\ne : e \"+\" e | num ;", []string{
+			"<h1>Grammar (canonical LR(1))</h1>",
+			"<tr><td>0</td><td>e -&gt; e &#43; e</td><td></td></tr>",
+			"<div class=\"state\" id=\"state0\">",
+			"<span class=\"closure\">e -&gt; • num, $ &#43;</span>",
+			"<tr><td>num</td><td>shift, and go to <a href=\"#state1\">state 1</a></td></tr>",
+			"<p>Reached from <a href=\"#state0\">state 0</a>, <a href=\"#state3\">state 3</a></p>",
+			"<div class=\"state unresolved\" id=\"state4\">",
+			"<tr class=\"unresolved\"><td>&#43;</td><td>shift, and go to <a href=\"#state3\">state 3</a></td></tr>",
+			"<li>state 4: shift/reduce conflict on &#43; between",
+		}},
+		{"precedence", "%token num /\\d+/\n%left \"+\"\ne : e \"+\" e | num ;", []string{
+			"<div class=\"state resolved\" id=\"state4\">",
+			"<tr class=\"resolved\"><td>&#43;</td><td>reduce using rule 0 (e -&gt; e &#43; e)</td></tr>",
+		}},
+	}
+	for _, tc := range tests {
+		var b bytes.Buffer
+		if err := compileReported(t, tc.src).WriteHTMLReport(&b); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tc.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("%v: HTML report does not contain %q:\n%v", tc.name, want, b.String())
+			}
+		}
+		if n := strings.Count(b.String(), "<div class=\"state"); n != 5 {
+			t.Errorf("%v: HTML report has %v states, want 5", tc.name, n)
+		}
+	}
+}
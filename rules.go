@@ -0,0 +1,108 @@
+//go:build go1.18
+
+package gdpgen
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Sym is a symbol of a grammar whose semantic values have type T: Token
+// for terminals, the declared result type for non-terminals. Syms are made
+// by Term and NonTerm and used with the typed rules Rule0 to Rule6, so that
+// the actions are type-checked by the compiler:
+//
+//	expr := NonTerm[int](g, "expr")
+//	plus, number := Term(g, "+"), Term(g, "number")
+//	Rule3(g, expr, expr, plus, expr, func(l int, _ Token, r int) int { return l + r })
+//	Rule1(g, expr, number, func(n Token) int { v, _ := strconv.Atoi(n.Value); return v })
+type Sym[T any] struct {
+	elem *ProductElem
+}
+
+// Elem returns the symbol of s.
+func (s Sym[T]) Elem() *ProductElem {
+	return s.elem
+}
+
+// Term returns the terminal of g called name. Its values are Tokens.
+func Term(g *G, name string) Sym[Token] {
+	return Sym[Token]{g.Terminal(name)}
+}
+
+// NonTerm returns the non-terminal of g called name and declares that its
+// values have type T. G.Validate reports a non-terminal declared with
+// different types, and products of it that were not added by a typed rule.
+func NonTerm[T any](g *G, name string) Sym[T] {
+	nonTerm := g.NonTerminal(name)
+	g.declareType(nonTerm, reflect.TypeOf((*T)(nil)).Elem())
+	return Sym[T]{nonTerm}
+}
+
+// valueAt returns values[i] as a T. A nil value, such as the one of an
+// empty product, gives the zero T.
+func valueAt[T any](values []interface{}, i int) T {
+	v, ok := values[i].(T)
+	if !ok && values[i] != nil {
+		panic(fmt.Sprintf("value %v of type %T is not a %v", values[i], values[i], reflect.TypeOf((*T)(nil)).Elem()))
+	}
+	return v
+}
+
+// addRule adds a product added by a typed rule to g.
+func (g *G) addRule(head *ProductElem, body []*ProductElem, callback func([]interface{}) interface{}) *Product {
+	p := &Product{head, body, callback}
+	g.AddProduct(p)
+	g.metaOf(p).typed = true
+	return p
+}
+
+// Rule0 adds the empty product head -> %empty, whose value is action().
+func Rule0[R any](g *G, head Sym[R], action func() R) *Product {
+	return g.addRule(head.elem, []*ProductElem{EmptyElem}, func([]interface{}) interface{} {
+		return action()
+	})
+}
+
+// Rule1 adds the product head -> a, whose value is action applied
+// to the values of the body.
+func Rule1[A, R any](g *G, head Sym[R], a Sym[A], action func(A) R) *Product {
+	return g.addRule(head.elem, []*ProductElem{a.elem}, func(values []interface{}) interface{} {
+		return action(valueAt[A](values, 0))
+	})
+}
+
+// Rule2 is Rule1 for a body of 2 symbols.
+func Rule2[A, B, R any](g *G, head Sym[R], a Sym[A], b Sym[B], action func(A, B) R) *Product {
+	return g.addRule(head.elem, []*ProductElem{a.elem, b.elem}, func(values []interface{}) interface{} {
+		return action(valueAt[A](values, 0), valueAt[B](values, 1))
+	})
+}
+
+// Rule3 is Rule1 for a body of 3 symbols.
+func Rule3[A, B, C, R any](g *G, head Sym[R], a Sym[A], b Sym[B], c Sym[C], action func(A, B, C) R) *Product {
+	return g.addRule(head.elem, []*ProductElem{a.elem, b.elem, c.elem}, func(values []interface{}) interface{} {
+		return action(valueAt[A](values, 0), valueAt[B](values, 1), valueAt[C](values, 2))
+	})
+}
+
+// Rule4 is Rule1 for a body of 4 symbols.
+func Rule4[A, B, C, D, R any](g *G, head Sym[R], a Sym[A], b Sym[B], c Sym[C], d Sym[D], action func(A, B, C, D) R) *Product {
+	return g.addRule(head.elem, []*ProductElem{a.elem, b.elem, c.elem, d.elem}, func(values []interface{}) interface{} {
+		return action(valueAt[A](values, 0), valueAt[B](values, 1), valueAt[C](values, 2), valueAt[D](values, 3))
+	})
+}
+
+// Rule5 is Rule1 for a body of 5 symbols.
+func Rule5[A, B, C, D, E, R any](g *G, head Sym[R], a Sym[A], b Sym[B], c Sym[C], d Sym[D], e Sym[E], action func(A, B, C, D, E) R) *Product {
+	return g.addRule(head.elem, []*ProductElem{a.elem, b.elem, c.elem, d.elem, e.elem}, func(values []interface{}) interface{} {
+		return action(valueAt[A](values, 0), valueAt[B](values, 1), valueAt[C](values, 2), valueAt[D](values, 3), valueAt[E](values, 4))
+	})
+}
+
+// Rule6 is Rule1 for a body of 6 symbols.
+func Rule6[A, B, C, D, E, F, R any](g *G, head Sym[R], a Sym[A], b Sym[B], c Sym[C], d Sym[D], e Sym[E], f Sym[F], action func(A, B, C, D, E, F) R) *Product {
+	return g.addRule(head.elem, []*ProductElem{a.elem, b.elem, c.elem, d.elem, e.elem, f.elem}, func(values []interface{}) interface{} {
+		return action(valueAt[A](values, 0), valueAt[B](values, 1), valueAt[C](values, 2), valueAt[D](values, 3), valueAt[E](values, 4), valueAt[F](values, 5))
+	})
+}
@@ -0,0 +1,75 @@
+//go:build go1.18
+
+package gdpgen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTypedRules(t *testing.T) {
+	g := NewGrammar(NewNonTerminal("list"))
+	list, item := NonTerm[[]string](g, "list"), NonTerm[string](g, "item")
+	id := Term(g, "id")
+	g.AddPattern("id", `[a-z]+`)
+	punct := map[string]Sym[Token]{}
+	for _, p := range []string{"(", ")", "[", "]", "<", ",", ">", "{", ";", "}"} {
+		g.AddPattern(p, `\`+p)
+		punct[p] = Term(g, p)
+	}
+
+	Rule0(g, list, func() []string { return []string{} })
+	Rule2(g, list, list, item, func(l []string, i string) []string { return append(l, i) })
+	Rule1(g, item, id, func(t Token) string { return t.Value })
+	Rule3(g, item, punct["("], item, punct[")"], func(_ Token, i string, _ Token) string {
+		return "(" + i + ")"
+	})
+	Rule4(g, item, punct["["], item, item, punct["]"], func(_ Token, a, b string, _ Token) string {
+		return "[" + a + " " + b + "]"
+	})
+	Rule5(g, item, punct["<"], item, punct[","], item, punct[">"], func(_ Token, a string, _ Token, b string, _ Token) string {
+		return "<" + a + "," + b + ">"
+	})
+	Rule6(g, item, punct["{"], item, punct[";"], item, punct[";"], punct["}"], func(_ Token, a string, _ Token, b string, _, _ Token) string {
+		return "{" + a + ";" + b + ";}"
+	})
+
+	if diagnostics := g.Validate(); len(diagnostics) != 0 {
+		t.Fatalf("diagnostics %v", diagnostics)
+	}
+	cg, err := Compile(g, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := cg.NewParser(g.NewLexer()).Parse("a (b) [c d] <e, f> {g; h;}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a", "(b)", "[c d]", "<e,f>", "{g;h;}"}
+	if got, ok := value.([]string); !ok || !reflect.DeepEqual(got, want) {
+		t.Errorf("Parse = %#v, want %#v", value, want)
+	}
+}
+
+func TestTypedRulesMismatch(t *testing.T) {
+	g := NewGrammar(NewNonTerminal("s"))
+	s, x := NonTerm[int](g, "s"), Term(g, "x")
+	Rule1(g, s, x, func(Token) int { return 1 })
+	// an untyped product of a typed non-terminal is only a warning
+	g.AddProduct(&Product{s.Elem(), []*ProductElem{x.Elem(), x.Elem()}, nil})
+	if _, err := Compile(g); err != nil {
+		t.Fatalf("Compile with an untyped product: %v", err)
+	}
+	diagnostics := g.Validate()
+	if len(diagnostics) != 1 || diagnostics[0].Kind != TYPE_MISMATCH || diagnostics[0].Severity != SEVERITY_WARNING {
+		t.Errorf("diagnostics %v, want a TYPE_MISMATCH warning", diagnostics)
+	}
+
+	// declaring s again with another type is an error
+	Rule1(g, NonTerm[string](g, "s"), x, func(t Token) string { return t.Value })
+	_, err := Compile(g)
+	ve, ok := err.(*ValidationError)
+	if !ok || len(ve.Diagnostics) != 1 || ve.Diagnostics[0].Kind != TYPE_MISMATCH || ve.Diagnostics[0].Symbol != s.Elem() {
+		t.Errorf("Compile = %v, want a TYPE_MISMATCH error for s", err)
+	}
+}
@@ -0,0 +1,528 @@
+package gdpgen
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// binaryMagic starts the binary encoding of a CompiledGrammar.
+const binaryMagic = "GDPGEN\x00"
+
+// tableFormatVersion is increased whenever the encoding changes.
+const tableFormatVersion = 1
+
+const (
+	tableTerminal = iota
+	tableNonTerminal
+	tableEndOfInput
+	tableAugStart
+)
+
+// tableFile is the encoded form of a CompiledGrammar. Symbols are referred
+// to by their index in Symbols, EmptyElem by -1, and products by their
+// index in Products, which is their index in the compiled G.
+type tableFile struct {
+	Version     int             `json:"version"`
+	Fingerprint string          `json:"fingerprint"`
+	Mode        TableMode       `json:"mode"`
+	Symbols     []tableSymbol   `json:"symbols"`
+	Start       int             `json:"start"`
+	Products    []tableProduct  `json:"products"`
+	Patterns    [][2]string     `json:"patterns"`
+	Actions     []tableAction   `json:"actions"`
+	Candidates  []tableAction   `json:"candidates"`
+	Gotos       [][3]int        `json:"gotos"`
+	Conflicts   []tableConflict `json:"conflicts"`
+}
+
+type tableSymbol struct {
+	Name string `json:"name"`
+	Kind int    `json:"kind"`
+}
+
+type tableProduct struct {
+	Head  int    `json:"head"`
+	Body  []int  `json:"body"`
+	Label string `json:"label,omitempty"`
+}
+
+// tableAction is a shift to Arg, a reduce by product Arg, an accept or an
+// error for the lookahead Term in State.
+type tableAction struct {
+	State int        `json:"state"`
+	Term  int        `json:"term"`
+	Op    actioncode `json:"op"`
+	Arg   int        `json:"arg"`
+}
+
+type tableConflict struct {
+	Kind         ConflictKind `json:"kind"`
+	State        int          `json:"state"`
+	Lookahead    int          `json:"lookahead"`
+	ShiftTo      int          `json:"shiftTo"`
+	Products     []int        `json:"products"`
+	Items        [][3]int     `json:"items"` // product, dot, lookahead or -1
+	Resolution   Resolution   `json:"resolution"`
+	Chosen       int          `json:"chosen"`
+	ByPrecedence bool         `json:"byPrecedence"`
+	Reason       string       `json:"reason"`
+	MergeInduced bool         `json:"mergeInduced"`
+}
+
+// Fingerprint returns a hash of everything in g that the parsing tables and
+// the lexer depend on: the start symbol, the products with their labels
+// and %prec terminals, the precedence declarations and the patterns.
+func (g *G) Fingerprint() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "start %q\n", g.StartSymbol.Sig)
+	for _, p := range g.Products {
+		if p.Head == augStartElem {
+			continue
+		}
+		fmt.Fprintf(&b, "product %q ->", p.Head.Sig)
+		for _, elem := range p.Body {
+			fmt.Fprintf(&b, " %v", fingerprintSymbol(elem))
+		}
+		// only the settings that reach the tables; span callbacks and typed
+		// rules also have product meta
+		if m, ok := g.meta[p]; ok && m.label != "" {
+			fmt.Fprintf(&b, " label %q", m.label)
+		}
+		if m, ok := g.meta[p]; ok && m.prec != nil {
+			fmt.Fprintf(&b, " prec %v", fingerprintSymbol(m.prec))
+		}
+		b.WriteByte('\n')
+	}
+	precs := []string{}
+	for term, prec := range g.precs {
+		precs = append(precs, fmt.Sprintf("precedence %v %v %v\n", fingerprintSymbol(term), prec.level, prec.assoc))
+	}
+	sort.Strings(precs)
+	for _, prec := range precs {
+		b.WriteString(prec)
+	}
+	for _, p := range g.patterns {
+		fmt.Fprintf(&b, "pattern %q %q\n", p.Name, p.Regex.String())
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:])
+}
+
+func fingerprintSymbol(elem *ProductElem) string {
+	switch {
+	case elem == EmptyElem:
+		return "%empty"
+	case elem.IsTerminal:
+		return fmt.Sprintf("t%q", elem.Sig)
+	}
+	return fmt.Sprintf("n%q", elem.Sig)
+}
+
+// Fingerprint returns the fingerprint of the grammar cg was compiled from.
+func (cg *CompiledGrammar) Fingerprint() string {
+	return cg.fingerprint
+}
+
+// MarshalBinary encodes the tables, symbols, products and patterns of cg,
+// but not the Callbacks.
+func (cg *CompiledGrammar) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(binaryMagic)
+	if err := gob.NewEncoder(&buf).Encode(cg.tableFile()); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON encodes cg like MarshalBinary, as JSON.
+func (cg *CompiledGrammar) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cg.tableFile())
+}
+
+func (cg *CompiledGrammar) tableFile() *tableFile {
+	g := cg.augG
+	f := &tableFile{
+		Version:     tableFormatVersion,
+		Fingerprint: cg.fingerprint,
+		Mode:        cg.mode,
+		Symbols:     []tableSymbol{{eot.Sig, tableEndOfInput}},
+	}
+
+	ids := map[*ProductElem]int{EmptyElem: -1, eot: 0}
+	for _, sym := range g.GetSymbolSet() {
+		if _, ok := ids[sym]; ok {
+			continue
+		}
+		kind := tableNonTerminal
+		switch {
+		case sym == augStartElem:
+			kind = tableAugStart
+		case sym.IsTerminal:
+			kind = tableTerminal
+		}
+		ids[sym] = len(f.Symbols)
+		f.Symbols = append(f.Symbols, tableSymbol{sym.Sig, kind})
+	}
+	f.Start = ids[g.StartSymbol]
+
+	prodIDs := make(map[*Product]int)
+	for i, p := range g.Products {
+		prodIDs[p] = i
+		if p.Head == augStartElem {
+			continue
+		}
+		body := []int{}
+		for _, elem := range p.Body {
+			body = append(body, ids[elem])
+		}
+		f.Products = append(f.Products, tableProduct{ids[p.Head], body, g.Label(p)})
+	}
+	for _, p := range g.patterns {
+		f.Patterns = append(f.Patterns, [2]string{p.Name, strings.TrimPrefix(p.Regex.String(), "^")})
+	}
+
+	encode := func(state int, term *ProductElem, act *action) tableAction {
+		arg := 0
+		switch act.op {
+		case shiftAction:
+			arg = act.state
+		case reduceAction:
+			arg = prodIDs[act.prod]
+		}
+		return tableAction{state, ids[term], act.op, arg}
+	}
+	t := cg.actionTable
+	states := []int{}
+	for state := range t.states {
+		states = append(states, state)
+	}
+	sort.Ints(states)
+	for _, state := range states {
+		terms := []*ProductElem{}
+		for term := range t.states[state] {
+			terms = append(terms, term)
+		}
+		sort.Slice(terms, func(i, j int) bool { return ids[terms[i]] < ids[terms[j]] })
+		for _, term := range terms {
+			f.Actions = append(f.Actions, encode(state, term, t.states[state][term]))
+			if t.ambiguous[state][term] {
+				for _, act := range t.candidates[state][term] {
+					f.Candidates = append(f.Candidates, encode(state, term, act))
+				}
+			}
+		}
+	}
+
+	states = []int{}
+	for state := range cg.gotoTable.states {
+		states = append(states, state)
+	}
+	sort.Ints(states)
+	for _, state := range states {
+		gotos := [][3]int{}
+		for head, to := range cg.gotoTable.states[state] {
+			gotos = append(gotos, [3]int{state, ids[head], to})
+		}
+		sort.Slice(gotos, func(i, j int) bool { return gotos[i][1] < gotos[j][1] })
+		f.Gotos = append(f.Gotos, gotos...)
+	}
+
+	for _, c := range t.conflicts {
+		tc := tableConflict{
+			Kind:         c.Kind,
+			State:        c.State,
+			Lookahead:    ids[c.Lookahead],
+			ShiftTo:      c.ShiftTo,
+			Products:     []int{},
+			Items:        [][3]int{},
+			Resolution:   c.Resolution,
+			Chosen:       -1,
+			ByPrecedence: c.ByPrecedence,
+			Reason:       c.Reason,
+			MergeInduced: c.MergeInduced,
+		}
+		for _, p := range c.Products {
+			tc.Products = append(tc.Products, prodIDs[p])
+		}
+		for _, it := range c.Items {
+			lookahead := -1
+			if it.Lookahead != nil {
+				lookahead = ids[it.Lookahead]
+			}
+			tc.Items = append(tc.Items, [3]int{prodIDs[it.Product], it.Dot, lookahead})
+		}
+		if c.Chosen != nil {
+			tc.Chosen = prodIDs[c.Chosen]
+		}
+		f.Conflicts = append(f.Conflicts, tc)
+	}
+	return f
+}
+
+// UnmarshalBinary decodes data written by MarshalBinary into cg.
+func (cg *CompiledGrammar) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		return errors.New("not a binary encoding of parsing tables")
+	}
+	f := &tableFile{}
+	if err := gob.NewDecoder(bytes.NewReader(data[len(binaryMagic):])).Decode(f); err != nil {
+		return err
+	}
+	return cg.load(f)
+}
+
+// UnmarshalJSON decodes data written by MarshalJSON into cg.
+func (cg *CompiledGrammar) UnmarshalJSON(data []byte) error {
+	f := &tableFile{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return err
+	}
+	return cg.load(f)
+}
+
+// LoadCompiledGrammar decodes tables written by MarshalBinary or
+// MarshalJSON. Unless fingerprint is empty, it fails if the tables were not
+// compiled from a grammar with that fingerprint, such as the one returned
+// by G.Fingerprint for the grammar of the running program. The loaded
+// products have no Callbacks; attach them with CallbacksFrom, SetCallback
+// or SetCallbackByLabel before parsing.
+func LoadCompiledGrammar(data []byte, fingerprint string) (*CompiledGrammar, error) {
+	cg := &CompiledGrammar{}
+	var err error
+	if bytes.HasPrefix(data, []byte(binaryMagic)) {
+		err = cg.UnmarshalBinary(data)
+	} else {
+		err = cg.UnmarshalJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if fingerprint != "" && cg.fingerprint != fingerprint {
+		return nil, errors.New(fmt.Sprintf("stale parsing tables: compiled from grammar %v, expected %v", cg.fingerprint, fingerprint))
+	}
+	return cg, nil
+}
+
+func (cg *CompiledGrammar) load(f *tableFile) error {
+	if f.Version != tableFormatVersion {
+		return errors.New(fmt.Sprintf("unsupported parsing table version %v", f.Version))
+	}
+	invalid := errors.New("invalid parsing tables")
+
+	syms := make([]*ProductElem, len(f.Symbols))
+	for i, sym := range f.Symbols {
+		switch sym.Kind {
+		case tableTerminal:
+			syms[i] = NewTerminal(sym.Name)
+		case tableNonTerminal:
+			syms[i] = NewNonTerminal(sym.Name)
+		case tableEndOfInput:
+			syms[i] = eot
+		case tableAugStart:
+			syms[i] = augStartElem
+		default:
+			return invalid
+		}
+	}
+	symbol := func(id int) *ProductElem {
+		if id == -1 {
+			return EmptyElem
+		}
+		if id < 0 || id >= len(syms) {
+			return nil
+		}
+		return syms[id]
+	}
+
+	start := symbol(f.Start)
+	if start == nil {
+		return invalid
+	}
+	g := NewGrammar(start)
+	for _, tp := range f.Products {
+		head := symbol(tp.Head)
+		if head == nil {
+			return invalid
+		}
+		p := &Product{head, []*ProductElem{}, nil}
+		for _, id := range tp.Body {
+			elem := symbol(id)
+			if elem == nil {
+				return invalid
+			}
+			p.Body = append(p.Body, elem)
+		}
+		g.AddProduct(p)
+		if tp.Label != "" {
+			g.SetLabel(p, tp.Label)
+		}
+	}
+	for _, pattern := range f.Patterns {
+		// AddPattern panics on a bad regex
+		if _, err := regexp.Compile("^" + pattern[1]); err != nil {
+			return invalid
+		}
+		g.AddPattern(pattern[0], pattern[1])
+	}
+
+	// every state has an action, so the rows give the number of states
+	nStates := 0
+	for _, ta := range append(append([]tableAction{}, f.Actions...), f.Candidates...) {
+		if ta.State >= nStates {
+			nStates = ta.State + 1
+		}
+	}
+	for _, entry := range f.Gotos {
+		if entry[0] >= nStates {
+			nStates = entry[0] + 1
+		}
+	}
+	isState := func(state int) bool {
+		return state >= 0 && state < nStates
+	}
+	augment(g)
+	product := func(id int) *Product {
+		if id < 0 || id >= len(g.Products) {
+			return nil
+		}
+		return g.Products[id]
+	}
+
+	t := newActionTable()
+	decode := func(ta tableAction) (*ProductElem, *action) {
+		term := symbol(ta.Term)
+		if term == nil || !term.IsTerminal || term == EmptyElem || !isState(ta.State) {
+			return nil, nil
+		}
+		switch ta.Op {
+		case shiftAction:
+			if isState(ta.Arg) {
+				return term, newShiftAction(ta.Arg)
+			}
+		case reduceAction:
+			if prod := product(ta.Arg); prod != nil {
+				return term, newReduceAction(prod)
+			}
+		case acceptAction:
+			return term, newAcceptAction()
+		case errorAction:
+			return term, newErrorAction()
+		}
+		return nil, nil
+	}
+	for _, ta := range f.Actions {
+		term, act := decode(ta)
+		if term == nil || act == nil {
+			return invalid
+		}
+		if _, ok := t.states[ta.State]; !ok {
+			t.states[ta.State] = make(map[*ProductElem]*action)
+		}
+		t.states[ta.State][term] = act
+	}
+	for _, ta := range f.Candidates {
+		term, act := decode(ta)
+		if term == nil || act == nil {
+			return invalid
+		}
+		t.set(ta.State, term, act)
+		t.markAmbiguous(ta.State, term)
+	}
+
+	gotoTable := newGoToTable()
+	for _, entry := range f.Gotos {
+		head := symbol(entry[1])
+		if head == nil || head.IsTerminal || !isState(entry[0]) || !isState(entry[2]) {
+			return invalid
+		}
+		gotoTable.set(entry[0], head, entry[2])
+	}
+
+	for _, tc := range f.Conflicts {
+		if !isState(tc.State) || symbol(tc.Lookahead) == nil || tc.ShiftTo != -1 && !isState(tc.ShiftTo) {
+			return invalid
+		}
+		c := &Conflict{
+			Kind:         tc.Kind,
+			State:        tc.State,
+			Lookahead:    symbol(tc.Lookahead),
+			ShiftTo:      tc.ShiftTo,
+			Products:     []*Product{},
+			Items:        []Item{},
+			Resolution:   tc.Resolution,
+			Chosen:       product(tc.Chosen),
+			ByPrecedence: tc.ByPrecedence,
+			Reason:       tc.Reason,
+			MergeInduced: tc.MergeInduced,
+		}
+		for _, id := range tc.Products {
+			if product(id) == nil {
+				return invalid
+			}
+			c.Products = append(c.Products, product(id))
+		}
+		for _, it := range tc.Items {
+			var lookahead *ProductElem
+			if it[2] >= 0 {
+				lookahead = symbol(it[2])
+			}
+			if product(it[0]) == nil {
+				return invalid
+			}
+			c.Items = append(c.Items, Item{product(it[0]), it[1], lookahead})
+		}
+		t.conflicts = append(t.conflicts, c)
+	}
+
+	*cg = CompiledGrammar{g, f.Mode, t, gotoTable, nil, f.Fingerprint}
+	return nil
+}
+
+// CallbacksFrom attaches the Callbacks and span callbacks of the products
+// of g to the corresponding products of cg. It fails if cg was not compiled
+// from a grammar with the fingerprint of g.
+func (cg *CompiledGrammar) CallbacksFrom(g *G) error {
+	if fingerprint := g.Fingerprint(); fingerprint != cg.fingerprint {
+		return errors.New(fmt.Sprintf("grammar %v does not match the parsing tables compiled from %v", fingerprint, cg.fingerprint))
+	}
+	for i, p := range g.Products {
+		cg.augG.Products[i].Callback = p.Callback
+		if m, ok := g.meta[p]; ok && m.spanCallback != nil {
+			cg.augG.metaOf(cg.augG.Products[i]).spanCallback = m.spanCallback
+		}
+	}
+	return nil
+}
+
+// SetCallback attaches callback to the product with index i in the grammar
+// cg was compiled from.
+func (cg *CompiledGrammar) SetCallback(i int, callback func([]interface{}) interface{}) error {
+	if i < 0 || i >= len(cg.augG.Products) || cg.augG.Products[i].Head == augStartElem {
+		return errors.New(fmt.Sprintf("no product %v", i))
+	}
+	cg.augG.Products[i].Callback = callback
+	return nil
+}
+
+// SetCallbackByLabel attaches callback to the product labeled label.
+func (cg *CompiledGrammar) SetCallbackByLabel(label string, callback func([]interface{}) interface{}) error {
+	p := cg.augG.ProductByLabel(label)
+	if p == nil {
+		return errors.New(fmt.Sprintf("no product labeled %q", label))
+	}
+	p.Callback = callback
+	return nil
+}
+
+// NewLexer returns a lexer for the patterns of the grammar.
+func (cg *CompiledGrammar) NewLexer() Lexer {
+	return cg.augG.NewLexer()
+}
@@ -0,0 +1,95 @@
+package gdpgen
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestLoadCompiledGrammar(t *testing.T) {
+	g := newCalcGrammar(t)
+	cg, err := Compile(g, WithTableMode(PAGER_LR1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary, err := cg.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	text, err := cg.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range map[string][]byte{"binary": binary, "JSON": text} {
+		loaded, err := LoadCompiledGrammar(data, g.Fingerprint())
+		if err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if loaded.Mode() != PAGER_LR1 || loaded.numStates() != cg.numStates() || len(loaded.Conflicts()) != len(cg.Conflicts()) {
+			t.Errorf("%v: loaded %v tables with %v states and %v conflicts", name, loaded.Mode(), loaded.numStates(), len(loaded.Conflicts()))
+		}
+		if err := loaded.CallbacksFrom(g); err != nil {
+			t.Errorf("%v: %v", name, err)
+			continue
+		}
+		if value, err := loaded.NewParser(loaded.NewLexer()).Parse("2 * (3 + 4)"); err != nil || value != 14 {
+			t.Errorf("%v: Parse = %v, %v, want 14", name, value, err)
+		}
+		if _, err := LoadCompiledGrammar(data, "stale"); err == nil {
+			t.Errorf("%v: tables loaded with a wrong fingerprint", name)
+		}
+	}
+}
+
+func TestLoadInvalidTables(t *testing.T) {
+	cg, err := Compile(newCalcGrammar(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := cg.MarshalJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(f *tableFile)
+	}{
+		{"version", func(f *tableFile) { f.Version++ }},
+		{"pattern", func(f *tableFile) { f.Patterns[0][1] = "(" }},
+		{"shift target", func(f *tableFile) {
+			for i := range f.Actions {
+				if f.Actions[i].Op == shiftAction {
+					f.Actions[i].Arg = 1000
+					return
+				}
+			}
+		}},
+		{"reduced product", func(f *tableFile) {
+			for i := range f.Actions {
+				if f.Actions[i].Op == reduceAction {
+					f.Actions[i].Arg = -2
+					return
+				}
+			}
+		}},
+		{"goto state", func(f *tableFile) { f.Gotos[0][2] = 1000 }},
+		{"goto symbol", func(f *tableFile) { f.Gotos[0][1] = 1000 }},
+		{"conflict state", func(f *tableFile) { f.Conflicts[0].State = -1 }},
+		{"conflict product", func(f *tableFile) { f.Conflicts[0].Products[0] = 1000 }},
+	}
+	for _, tc := range tests {
+		f := &tableFile{}
+		if err := json.Unmarshal(data, f); err != nil {
+			t.Fatal(err)
+		}
+		tc.modify(f)
+		modified, err := json.Marshal(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := LoadCompiledGrammar(modified, ""); err == nil {
+			t.Errorf("%v: invalid tables loaded", tc.name)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gdpgen
+
+// symbolTable interns the symbols of a grammar by name, separately for
+// terminals and non-terminals, and numbers them in the order they were
+// first seen.
+type symbolTable struct {
+	symbols      []*ProductElem
+	ids          map[*ProductElem]int
+	terminals    map[string]*ProductElem
+	nonTerminals map[string]*ProductElem
+	// clashes are the symbols whose name is already used by a symbol of the
+	// other kind
+	clashes []*ProductElem
+}
+
+func newSymbolTable() *symbolTable {
+	return &symbolTable{
+		[]*ProductElem{},
+		make(map[*ProductElem]int),
+		make(map[string]*ProductElem),
+		make(map[string]*ProductElem),
+		[]*ProductElem{},
+	}
+}
+
+// intern returns the symbol of the same kind and name as elem, registering
+// elem itself if there is none yet. EmptyElem and the symbols of the
+// augmented grammar are never interned.
+func (t *symbolTable) intern(elem *ProductElem) *ProductElem {
+	if elem == nil || elem == EmptyElem || elem == eot || elem == augStartElem {
+		return elem
+	}
+	if _, ok := t.ids[elem]; ok {
+		return elem
+	}
+	names, others := t.nonTerminals, t.terminals
+	if elem.IsTerminal {
+		names, others = t.terminals, t.nonTerminals
+	}
+	if existing, ok := names[elem.Sig]; ok {
+		return existing
+	}
+	if _, ok := others[elem.Sig]; ok {
+		t.clashes = append(t.clashes, elem)
+	}
+	names[elem.Sig] = elem
+	t.ids[elem] = len(t.symbols)
+	t.symbols = append(t.symbols, elem)
+	return elem
+}
+
+// lookup is intern without registering elem.
+func (t *symbolTable) lookup(elem *ProductElem) *ProductElem {
+	if _, ok := t.ids[elem]; ok || elem == nil {
+		return elem
+	}
+	names := t.nonTerminals
+	if elem.IsTerminal {
+		names = t.terminals
+	}
+	if existing, ok := names[elem.Sig]; ok {
+		return existing
+	}
+	return elem
+}
+
+func (g *G) table() *symbolTable {
+	if g.symbols == nil {
+		g.symbols = newSymbolTable()
+	}
+	return g.symbols
+}
+
+// Terminal returns the terminal of g called name, creating it on first use.
+func (g *G) Terminal(name string) *ProductElem {
+	if term, ok := g.table().terminals[name]; ok {
+		return term
+	}
+	return g.table().intern(NewTerminal(name))
+}
+
+// NonTerminal returns the non-terminal of g called name, creating it on
+// first use.
+func (g *G) NonTerminal(name string) *ProductElem {
+	if nonTerm, ok := g.table().nonTerminals[name]; ok {
+		return nonTerm
+	}
+	return g.table().intern(NewNonTerminal(name))
+}
+
+// SymbolID returns the number of sym in g. Symbols are numbered from 0 in
+// the order g first saw them.
+func (g *G) SymbolID(sym *ProductElem) (int, bool) {
+	id, ok := g.table().ids[sym]
+	return id, ok
+}
+
+// Symbols returns the symbols of g in the order of their ids.
+func (g *G) Symbols() []*ProductElem {
+	return append([]*ProductElem{}, g.table().symbols...)
+}
+
+// canonicalize replaces the symbols of p by the ones interned in g, so that
+// symbols created separately with the same kind and name are one symbol.
+func (g *G) canonicalize(p *Product) {
+	p.Head = g.table().intern(p.Head)
+	for i, elem := range p.Body {
+		p.Body[i] = g.table().intern(elem)
+	}
+}
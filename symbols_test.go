@@ -0,0 +1,34 @@
+package gdpgen
+
+import "testing"
+
+func TestInterning(t *testing.T) {
+	s := NewNonTerminal("s")
+	g := NewGrammar(s)
+	p1 := &Product{s, []*ProductElem{NewTerminal("x")}, nil}
+	p2 := &Product{NewNonTerminal("s"), []*ProductElem{NewTerminal("x"), NewTerminal("x")}, nil}
+	g.AddProduct(p1)
+	g.AddProduct(p2)
+
+	x := g.Terminal("x")
+	if p1.Body[0] != x || p2.Body[0] != x || p2.Body[1] != x {
+		t.Errorf("terminals x were not interned: %p %p %p, want %p", p1.Body[0], p2.Body[0], p2.Body[1], x)
+	}
+	if p2.Head != s || g.NonTerminal("s") != s {
+		t.Errorf("non-terminal s was not interned")
+	}
+	if terms := g.GetTerminals(); len(terms) != 1 || terms[0] != x {
+		t.Errorf("terminals %v, want x", terms)
+	}
+	if got := sigs(g.Symbols()); got != "s x" {
+		t.Errorf("Symbols() = %v, want s x", got)
+	}
+	if id, ok := g.SymbolID(NewTerminal("x")); ok {
+		t.Errorf("SymbolID of a symbol not in g = %v", id)
+	}
+
+	// a terminal and a non-terminal of the same name stay apart
+	if g.NonTerminal("x") == x {
+		t.Errorf("NonTerminal(x) is the terminal x")
+	}
+}
@@ -0,0 +1,86 @@
+package gdpgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteTables writes every state of cg with its kernel items, its actions
+// by terminal, its gotos by non-terminal and the conflicts met in it.
+// Tables loaded with LoadCompiledGrammar have no items to show.
+func (cg *CompiledGrammar) WriteTables(w io.Writer) error {
+	idx := cg.augG.analysis()
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, "%v, %v states\n", cg.mode, cg.numStates())
+	for state := 0; state < cg.numStates(); state++ {
+		fmt.Fprintf(b, "\nstate %v\n", state)
+		if cg.automaton != nil {
+			s := cg.automaton.states[state]
+			for k, it := range s.kernel {
+				las := []string{}
+				for _, term := range s.lookaheads[k].members() {
+					las = append(las, idx.symbols[term].Sig)
+				}
+				item := Item{idx.products[it.prod], it.dot, nil}
+				fmt.Fprintf(b, "    %v, %v\n", item, strings.Join(las, " "))
+			}
+			b.WriteString("\n")
+		}
+		for _, term := range idx.symbols[:idx.nTerms] {
+			act, ok := cg.actionTable.states[state][term]
+			if !ok {
+				continue
+			}
+			switch act.op {
+			case shiftAction:
+				fmt.Fprintf(b, "    %v  shift %v\n", term.Sig, act.state)
+			case reduceAction:
+				fmt.Fprintf(b, "    %v  reduce %v\n", term.Sig, productRule(act.prod))
+			case acceptAction:
+				fmt.Fprintf(b, "    %v  accept\n", term.Sig)
+			case errorAction:
+				fmt.Fprintf(b, "    %v  error\n", term.Sig)
+			}
+		}
+		for _, nonTerm := range idx.symbols[idx.nTerms:] {
+			if to, ok := cg.gotoTable.states[state][nonTerm]; ok {
+				fmt.Fprintf(b, "    %v  goto %v\n", nonTerm.Sig, to)
+			}
+		}
+		for _, c := range cg.Conflicts() {
+			if c.State == state {
+				fmt.Fprintf(b, "    %v\n", c)
+			}
+		}
+	}
+	return b.Flush()
+}
+
+// numStates returns the number of states of the tables of cg.
+func (cg *CompiledGrammar) numStates() int {
+	if cg.automaton != nil {
+		return len(cg.automaton.states)
+	}
+	n := 0
+	for state := range cg.actionTable.states {
+		if state >= n {
+			n = state + 1
+		}
+	}
+	for state := range cg.gotoTable.states {
+		if state >= n {
+			n = state + 1
+		}
+	}
+	return n
+}
+
+func productRule(p *Product) string {
+	words := []string{p.Head.Sig, "->"}
+	for _, elem := range p.Body {
+		words = append(words, symbolName(elem))
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,61 @@
+package gdpgen
+
+import (
+	"io"
+	"log"
+)
+
+// Tracer watches Parser.Parse run. Every event gets the state stack and the
+// semantic value stack as they are after it; both belong to the parser and
+// must not be modified or kept after the call returns.
+type Tracer interface {
+	// OnShift is called when token is shifted and state is entered.
+	OnShift(state int, token Token, states []int, values []interface{})
+	// OnReduce is called when the body of prod is reduced in state, after its
+	// Callback has run.
+	OnReduce(prod *Product, state int, states []int, values []interface{})
+	// OnGoto is called after a reduction to head exposes state from and
+	// the parser enters state to.
+	OnGoto(from int, head *ProductElem, to int, states []int, values []interface{})
+	// OnAccept is called with the value Parse returns.
+	OnAccept(value interface{}, states []int, values []interface{})
+	// OnError is called in state with the token that could not be parsed
+	// and the error Parse returns.
+	OnError(state int, token Token, err error, states []int, values []interface{})
+}
+
+// SetTracer makes Parse report its steps to t; nil turns tracing off. Set
+// it before the parser is used by several goroutines, and give it a Tracer
+// that is safe for concurrent use if they parse at the same time.
+func (parser *Parser) SetTracer(t Tracer) {
+	parser.tracer = t
+}
+
+type logTracer struct {
+	logger *log.Logger
+}
+
+// NewLogTracer returns a Tracer that writes one line per event to w.
+func NewLogTracer(w io.Writer) Tracer {
+	return &logTracer{log.New(w, "[Parser] ", 0)}
+}
+
+func (t *logTracer) OnShift(state int, token Token, states []int, values []interface{}) {
+	t.logger.Printf("shift %v, go to state %v, states: %v", token, state, states)
+}
+
+func (t *logTracer) OnReduce(prod *Product, state int, states []int, values []interface{}) {
+	t.logger.Printf("reduce by %v in state %v, values: %v", productRule(prod), state, values)
+}
+
+func (t *logTracer) OnGoto(from int, head *ProductElem, to int, states []int, values []interface{}) {
+	t.logger.Printf("goto on %v from state %v to state %v, states: %v", head.Sig, from, to, states)
+}
+
+func (t *logTracer) OnAccept(value interface{}, states []int, values []interface{}) {
+	t.logger.Printf("accept %v", value)
+}
+
+func (t *logTracer) OnError(state int, token Token, err error, states []int, values []interface{}) {
+	t.logger.Printf("error in state %v on %v: %v", state, token, err)
+}
@@ -0,0 +1,73 @@
+package gdpgen
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// recordingTracer records the events of a parse, checking that the state
+// stack it gets ends in the state entered.
+type recordingTracer struct {
+	t      *testing.T
+	events []string
+}
+
+func (r *recordingTracer) top(event string, state int, states []int) {
+	if len(states) == 0 || states[len(states)-1] != state {
+		r.t.Errorf("%v: state %v, states %v", event, state, states)
+	}
+}
+
+func (r *recordingTracer) OnShift(state int, token Token, states []int, values []interface{}) {
+	r.top("shift", state, states)
+	r.events = append(r.events, "shift "+token.Value)
+}
+
+func (r *recordingTracer) OnReduce(prod *Product, state int, states []int, values []interface{}) {
+	r.events = append(r.events, "reduce "+productRule(prod))
+}
+
+func (r *recordingTracer) OnGoto(from int, head *ProductElem, to int, states []int, values []interface{}) {
+	r.top("goto", to, states)
+	r.events = append(r.events, "goto "+head.Sig)
+}
+
+func (r *recordingTracer) OnAccept(value interface{}, states []int, values []interface{}) {
+	r.events = append(r.events, fmt.Sprintf("accept %v", value))
+}
+
+func (r *recordingTracer) OnError(state int, token Token, err error, states []int, values []interface{}) {
+	r.top("error", state, states)
+	r.events = append(r.events, "error "+token.Value)
+}
+
+func TestTracer(t *testing.T) {
+	g := newCalcGrammar(t)
+	cg, err := Compile(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input  string
+		ok     bool
+		events string
+	}{
+		{"1 + 2", true, "shift 1, reduce e -> number, goto e, shift +, shift 2, reduce e -> number, goto e, " +
+			"reduce e -> e + e, goto e, accept 3"},
+		{"1 + + 2", false, "shift 1, reduce e -> number, goto e, shift +, error +"},
+		{"", false, "error "},
+	}
+	for _, tc := range tests {
+		parser := cg.NewParser(g.NewLexer())
+		tracer := &recordingTracer{t, []string{}}
+		parser.SetTracer(tracer)
+		_, err := parser.Parse(tc.input)
+		if got := strings.Join(tracer.events, ", "); got != tc.events {
+			t.Errorf("Parse(%q) traced %v, want %v", tc.input, got, tc.events)
+		}
+		if (err == nil) != tc.ok {
+			t.Errorf("Parse(%q) = %v, want ok %v", tc.input, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package gdpgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Node is a node of the concrete syntax tree returned by Parser.ParseTree.
+// A leaf holds the Token of a terminal; an inner node holds the Product it
+// was reduced by and one child per symbol of its body, %empty left out.
+type Node struct {
+	Symbol   *ProductElem
+	Product  *Product
+	Label    string
+	Children []*Node
+	Token    *Token
+	// Span is the text the node covers. An empty node starts and ends where
+	// the next token starts.
+	Span
+}
+
+func newLeaf(term *ProductElem, token Token) *Node {
+	return &Node{term, nil, "", []*Node{}, &token, token.Span}
+}
+
+func (parser *Parser) newNode(prod *Product, values []interface{}, span Span) *Node {
+	node := &Node{prod.Head, prod, parser.augG.Label(prod), []*Node{}, nil, span}
+	for _, value := range values {
+		if child, ok := value.(*Node); ok {
+			node.Children = append(node.Children, child)
+		}
+	}
+	return node
+}
+
+// IsLeaf reports whether n holds a token.
+func (n *Node) IsLeaf() bool {
+	return n.Token != nil
+}
+
+// Text returns the part of input, the text that was parsed, spanned by n.
+func (n *Node) Text(input string) string {
+	return input[n.Start:n.End]
+}
+
+// Walk calls visit for n and its descendants in depth-first order. The
+// children of a node are skipped if visit returns false for it.
+func (n *Node) Walk(visit func(*Node) bool) {
+	if !visit(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(visit)
+	}
+}
+
+// String returns the tree below n, one node per line, indented by depth.
+func (n *Node) String() string {
+	var b strings.Builder
+	var write func(*Node, int)
+	write = func(n *Node, depth int) {
+		b.WriteString(strings.Repeat("  ", depth))
+		if n.IsLeaf() {
+			fmt.Fprintf(&b, "%v %q [%v:%v]\n", n.Symbol.Sig, n.Token.Value, n.Start, n.End)
+			return
+		}
+		fmt.Fprintf(&b, "%v [%v:%v]", n.Symbol.Sig, n.Start, n.End)
+		if n.Label != "" {
+			fmt.Fprintf(&b, " {%v}", n.Label)
+		}
+		b.WriteString("\n")
+		for _, child := range n.Children {
+			write(child, depth+1)
+		}
+	}
+	write(n, 0)
+	return b.String()
+}
+
+type jsonNode struct {
+	Symbol   string      `json:"symbol"`
+	Rule     string      `json:"rule,omitempty"`
+	Label    string      `json:"label,omitempty"`
+	Token    string      `json:"token,omitempty"`
+	Value    *string     `json:"value,omitempty"`
+	Start    int         `json:"start"`
+	End      int         `json:"end"`
+	Line     int         `json:"line"`
+	Column   int         `json:"column"`
+	Children []*jsonNode `json:"children,omitempty"`
+}
+
+func (n *Node) toJSON() *jsonNode {
+	j := &jsonNode{Symbol: n.Symbol.Sig, Label: n.Label, Start: n.Start, End: n.End, Line: n.Line, Column: n.Column}
+	if n.IsLeaf() {
+		j.Token = n.Token.Name
+		j.Value = &n.Token.Value
+		return j
+	}
+	j.Rule = productRule(n.Product)
+	for _, child := range n.Children {
+		j.Children = append(j.Children, child.toJSON())
+	}
+	return j
+}
+
+// MarshalJSON encodes the tree below n. Every node has its symbol and span;
+// leaves also have the token name and value, inner nodes the rule they were
+// reduced by, its label, and their children.
+func (n *Node) MarshalJSON() ([]byte, error) {
+	return json.Marshal(n.toJSON())
+}
@@ -0,0 +1,79 @@
+package gdpgen
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseTree(t *testing.T) {
+	g, err := ParseGrammar(strings.NewReader(`
+%token id /[a-z]+/
+s : "(" (id ** ",") ")" {list} | id ;
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cg, err := Compile(g, Strict())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser := cg.NewParser(g.NewLexer())
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"x", `s [0:1]
+  id "x" [0:1]
+`},
+		{"( )", `s [0:3] {list}
+  ( "(" [0:1]
+  (id ** ,) [2:2]
+    id ** , [2:2]
+  ) ")" [2:3]
+`},
+		{"(a, b)", `s [0:6] {list}
+  ( "(" [0:1]
+  (id ** ,) [1:5]
+    id ** , [1:5]
+      id ++ , [1:5]
+        id ++ , [1:2]
+          id "a" [1:2]
+        , "," [2:3]
+        id "b" [4:5]
+  ) ")" [5:6]
+`},
+	}
+	for _, tc := range tests {
+		tree, err := parser.ParseTree(tc.input)
+		if err != nil {
+			t.Errorf("ParseTree(%q): %v", tc.input, err)
+			continue
+		}
+		if got := tree.String(); got != tc.want {
+			t.Errorf("ParseTree(%q) =\n%v\nwant\n%v", tc.input, got, tc.want)
+		}
+		tree.Walk(func(n *Node) bool {
+			if n.IsLeaf() && n.Text(tc.input) != n.Token.Value {
+				t.Errorf("%q: leaf %v covers %q", tc.input, n.Token, n.Text(tc.input))
+			}
+			return true
+		})
+	}
+
+	tree, err := parser.ParseTree("(a)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded["symbol"] != "s" || decoded["label"] != "list" || decoded["end"] != 3.0 || len(decoded["children"].([]interface{})) != 3 {
+		t.Errorf("MarshalJSON = %s", data)
+	}
+}
@@ -0,0 +1,42 @@
+package gdpgen
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// declareType records that the values of nonTerm have type t.
+func (g *G) declareType(nonTerm *ProductElem, t reflect.Type) {
+	if g.types == nil {
+		g.types = make(map[*ProductElem][]reflect.Type)
+	}
+	for _, declared := range g.types[nonTerm] {
+		if declared == t {
+			return
+		}
+	}
+	g.types[nonTerm] = append(g.types[nonTerm], t)
+}
+
+// typeDiagnostics reports the non-terminals declared with more than one
+// result type, and the products of typed non-terminals whose values are
+// not checked because they were not added by a typed rule.
+func (g *G) typeDiagnostics(products []*Product) (errs, warnings []*Diagnostic) {
+	for _, sym := range g.Symbols() {
+		types := g.types[sym]
+		if len(types) > 1 {
+			errs = append(errs, &Diagnostic{TYPE_MISMATCH, SEVERITY_ERROR, sym, g.GetProductsOf(sym),
+				fmt.Sprintf("%v is declared with the result types %v", sym.Sig, types)})
+		}
+		if len(types) == 0 {
+			continue
+		}
+		for _, p := range products {
+			if m, ok := g.meta[p]; p.Head == sym && (!ok || !m.typed) {
+				warnings = append(warnings, &Diagnostic{TYPE_MISMATCH, SEVERITY_WARNING, sym, []*Product{p},
+					fmt.Sprintf("%v has result type %v, but the value of %v is not checked", sym.Sig, types[0], productRule(p))})
+			}
+		}
+	}
+	return errs, warnings
+}
@@ -0,0 +1,338 @@
+package gdpgen
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	UNDEFINED_SYMBOL DiagnosticKind = iota
+	UNREACHABLE_SYMBOL
+	UNPRODUCTIVE_SYMBOL
+	DUPLICATE_PRODUCT
+	CYCLIC_DERIVATION
+	SYMBOL_CLASH
+	TYPE_MISMATCH
+)
+
+// DiagnosticKind tells what G.Validate found.
+type DiagnosticKind int
+
+func (k DiagnosticKind) String() string {
+	switch k {
+	case UNDEFINED_SYMBOL:
+		return "undefined symbol"
+	case UNREACHABLE_SYMBOL:
+		return "unreachable symbol"
+	case UNPRODUCTIVE_SYMBOL:
+		return "unproductive symbol"
+	case DUPLICATE_PRODUCT:
+		return "duplicate product"
+	case CYCLIC_DERIVATION:
+		return "cyclic derivation"
+	case SYMBOL_CLASH:
+		return "symbol clash"
+	case TYPE_MISMATCH:
+		return "type mismatch"
+	}
+	return "unknown"
+}
+
+const (
+	SEVERITY_ERROR Severity = iota
+	SEVERITY_WARNING
+)
+
+// Severity tells whether a diagnostic prevents building a parser.
+type Severity int
+
+func (s Severity) String() string {
+	if s == SEVERITY_ERROR {
+		return "error"
+	}
+	return "warning"
+}
+
+// Diagnostic is a problem of a grammar found by G.Validate.
+type Diagnostic struct {
+	Kind     DiagnosticKind
+	Severity Severity
+	// Symbol is the symbol concerned, if any.
+	Symbol *ProductElem
+	// Products are the products concerned: the duplicates, or the products
+	// along a cyclic derivation.
+	Products []*Product
+	Msg      string
+}
+
+func (d *Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v: %v", d.Severity, d.Kind, d.Msg)
+}
+
+// ValidationError is returned when a grammar has diagnostics of
+// SEVERITY_ERROR.
+type ValidationError struct {
+	Diagnostics []*Diagnostic
+}
+
+func (e *ValidationError) Error() string {
+	msgs := []string{}
+	for _, d := range e.Diagnostics {
+		msgs = append(msgs, d.String())
+	}
+	return "invalid grammar: " + strings.Join(msgs, "; ")
+}
+
+// Validate checks g and returns its diagnostics, errors first:
+//
+//   - non-terminals that are used but have no products (error)
+//   - non-terminals that derive no terminal string (error)
+//   - non-terminals that derive themselves, A =>+ A (error)
+//   - names used for both a terminal and a non-terminal (error)
+//   - non-terminals declared with several result types (error)
+//   - products of typed non-terminals added without a typed rule (warning)
+//   - symbols that cannot be reached from StartSymbol (warning)
+//   - products declared more than once (warning)
+func (g *G) Validate() []*Diagnostic {
+	products := []*Product{}
+	for _, p := range g.Products {
+		if p.Head != augStartElem {
+			products = append(products, p)
+		}
+	}
+	symbols := []*ProductElem{}
+	for _, sym := range g.GetSymbolSet() {
+		if sym != augStartElem && sym != EmptyElem {
+			symbols = append(symbols, sym)
+		}
+	}
+	defined := make(map[*ProductElem]bool)
+	for _, p := range products {
+		defined[p.Head] = true
+	}
+
+	errs := []*Diagnostic{}
+	warnings := []*Diagnostic{}
+
+	// undefined
+	undefined := make(map[*ProductElem]bool)
+	if !defined[g.StartSymbol] {
+		undefined[g.StartSymbol] = true
+		errs = append(errs, &Diagnostic{UNDEFINED_SYMBOL, SEVERITY_ERROR, g.StartSymbol, []*Product{},
+			fmt.Sprintf("start symbol %v has no products", g.StartSymbol.Sig)})
+	}
+	for _, sym := range symbols {
+		if !sym.IsTerminal && !defined[sym] && !undefined[sym] {
+			undefined[sym] = true
+			errs = append(errs, &Diagnostic{UNDEFINED_SYMBOL, SEVERITY_ERROR, sym, g.productsUsing(sym),
+				fmt.Sprintf("%v is used but has no products", sym.Sig)})
+		}
+	}
+
+	// unproductive
+	productive := make(map[*ProductElem]bool)
+	changed := true
+	for changed {
+		changed = false
+		for _, p := range products {
+			if productive[p.Head] {
+				continue
+			}
+			all := true
+			for _, elem := range p.Body {
+				if !elem.IsTerminal && !productive[elem] {
+					all = false
+					break
+				}
+			}
+			if all {
+				productive[p.Head] = true
+				changed = true
+			}
+		}
+	}
+	for _, sym := range symbols {
+		if !sym.IsTerminal && defined[sym] && !productive[sym] {
+			errs = append(errs, &Diagnostic{UNPRODUCTIVE_SYMBOL, SEVERITY_ERROR, sym, g.GetProductsOf(sym),
+				fmt.Sprintf("%v derives no terminal string", sym.Sig)})
+		}
+	}
+
+	// cyclic
+	errs = append(errs, g.cyclicDerivations(symbols, products)...)
+
+	// clash
+	for _, sym := range g.table().clashes {
+		errs = append(errs, &Diagnostic{SYMBOL_CLASH, SEVERITY_ERROR, sym, g.productsUsing(sym),
+			fmt.Sprintf("%v is the name of both a terminal and a non-terminal", sym.Sig)})
+	}
+
+	// types
+	typeErrs, typeWarnings := g.typeDiagnostics(products)
+	errs = append(errs, typeErrs...)
+	warnings = append(warnings, typeWarnings...)
+
+	// unreachable
+	reached := map[*ProductElem]bool{g.StartSymbol: true}
+	queue := []*ProductElem{g.StartSymbol}
+	for len(queue) > 0 {
+		head := queue[0]
+		queue = queue[1:]
+		for _, p := range products {
+			if p.Head != head {
+				continue
+			}
+			for _, elem := range p.Body {
+				if !reached[elem] {
+					reached[elem] = true
+					queue = append(queue, elem)
+				}
+			}
+		}
+	}
+	for _, sym := range symbols {
+		if !reached[sym] {
+			warnings = append(warnings, &Diagnostic{UNREACHABLE_SYMBOL, SEVERITY_WARNING, sym, []*Product{},
+				fmt.Sprintf("%v cannot be reached from %v", sym.Sig, g.StartSymbol.Sig)})
+		}
+	}
+
+	// duplicate
+	for i, p := range products {
+		for _, q := range products[:i] {
+			if p.Head == q.Head && CompareProductElem(p.Body, q.Body) {
+				warnings = append(warnings, &Diagnostic{DUPLICATE_PRODUCT, SEVERITY_WARNING, p.Head, []*Product{q, p},
+					fmt.Sprintf("%v is declared more than once", p)})
+				break
+			}
+		}
+	}
+
+	return append(errs, warnings...)
+}
+
+// unit is a step A => B of a derivation where the other symbols of prod
+// vanish.
+type unit struct {
+	to   *ProductElem
+	prod *Product
+}
+
+func reaches(units map[*ProductElem][]unit, from, to *ProductElem) bool {
+	seen := map[*ProductElem]bool{from: true}
+	queue := []*ProductElem{from}
+	for len(queue) > 0 {
+		sym := queue[0]
+		queue = queue[1:]
+		if sym == to {
+			return true
+		}
+		for _, u := range units[sym] {
+			if !seen[u.to] {
+				seen[u.to] = true
+				queue = append(queue, u.to)
+			}
+		}
+	}
+	return false
+}
+
+// cyclicDerivations reports every group of non-terminals deriving each
+// other through products whose other symbols are all nullable, once, with
+// the products along a shortest cycle. It analyses g afresh rather than
+// through G.analysis, so that Validate and Compile leave g untouched.
+func (g *G) cyclicDerivations(symbols []*ProductElem, products []*Product) []*Diagnostic {
+	idx := newGrammarIndex(g)
+	units := make(map[*ProductElem][]unit)
+	for _, p := range products {
+		for i, elem := range p.Body {
+			if elem.IsTerminal {
+				continue
+			}
+			rest := append(append([]*ProductElem{}, p.Body[:i]...), p.Body[i+1:]...)
+			if _, nullable := idx.firstOfSequence(rest); nullable {
+				units[p.Head] = append(units[p.Head], unit{elem, p})
+			}
+		}
+	}
+
+	diagnostics := []*Diagnostic{}
+	reported := make(map[*ProductElem]bool)
+	for _, sym := range symbols {
+		if sym.IsTerminal || reported[sym] {
+			continue
+		}
+		// breadth-first search for a shortest way back to sym
+		via := make(map[*ProductElem]unit)
+		queue := []*ProductElem{sym}
+		found := false
+		for len(queue) > 0 && !found {
+			from := queue[0]
+			queue = queue[1:]
+			for _, u := range units[from] {
+				if _, seen := via[u.to]; seen {
+					continue
+				}
+				via[u.to] = unit{from, u.prod}
+				if u.to == sym {
+					found = true
+					break
+				}
+				queue = append(queue, u.to)
+			}
+		}
+		if !found {
+			continue
+		}
+
+		path := []*ProductElem{sym}
+		prods := []*Product{}
+		for at := sym; ; {
+			step := via[at]
+			path = append([]*ProductElem{step.to}, path...)
+			prods = append([]*Product{step.prod}, prods...)
+			at = step.to
+			if at == sym {
+				break
+			}
+		}
+		// the other symbols on cycles through sym are covered by this one
+		for _, other := range symbols {
+			if reaches(units, sym, other) && reaches(units, other, sym) {
+				reported[other] = true
+			}
+		}
+		names := make([]string, len(path))
+		for i, elem := range path {
+			names[i] = elem.Sig
+		}
+		diagnostics = append(diagnostics, &Diagnostic{CYCLIC_DERIVATION, SEVERITY_ERROR, sym, prods,
+			fmt.Sprintf("%v derives itself: %v", sym.Sig, strings.Join(names, " => "))})
+	}
+	return diagnostics
+}
+
+// productsUsing returns the products with sym as head or in their body.
+func (g *G) productsUsing(sym *ProductElem) []*Product {
+	products := []*Product{}
+	for _, p := range g.Products {
+		if p.Head == sym || hasElem(p.Body, sym) {
+			products = append(products, p)
+		}
+	}
+	return products
+}
+
+// validate returns a ValidationError for the errors among the diagnostics.
+func validate(diagnostics []*Diagnostic) error {
+	errs := []*Diagnostic{}
+	for _, d := range diagnostics {
+		if d.Severity == SEVERITY_ERROR {
+			errs = append(errs, d)
+		}
+	}
+	if len(errs) > 0 {
+		return &ValidationError{errs}
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package gdpgen
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	type want struct {
+		kind     DiagnosticKind
+		severity Severity
+		symbol   string
+	}
+	tests := []struct {
+		name  string
+		build func(g *G, s *ProductElem)
+		want  []want
+	}{
+		{"valid", func(g *G, s *ProductElem) {
+			g.AddProduct(&Product{s, []*ProductElem{NewTerminal("x")}, nil})
+		}, nil},
+		{"undefined", func(g *G, s *ProductElem) {
+			g.AddProduct(&Product{s, []*ProductElem{NewNonTerminal("a")}, nil})
+		}, []want{{UNDEFINED_SYMBOL, SEVERITY_ERROR, "a"}, {UNPRODUCTIVE_SYMBOL, SEVERITY_ERROR, "s"}}},
+		{"undefined start", func(g *G, s *ProductElem) {
+			g.AddProduct(&Product{NewNonTerminal("a"), []*ProductElem{NewTerminal("x")}, nil})
+		}, []want{{UNDEFINED_SYMBOL, SEVERITY_ERROR, "s"}, {UNREACHABLE_SYMBOL, SEVERITY_WARNING, "a"}, {UNREACHABLE_SYMBOL, SEVERITY_WARNING, "x"}}},
+		{"unproductive", func(g *G, s *ProductElem) {
+			a := NewNonTerminal("a")
+			g.AddProduct(&Product{s, []*ProductElem{NewTerminal("x")}, nil})
+			g.AddProduct(&Product{s, []*ProductElem{a}, nil})
+			g.AddProduct(&Product{a, []*ProductElem{a, NewTerminal("y")}, nil})
+		}, []want{{UNPRODUCTIVE_SYMBOL, SEVERITY_ERROR, "a"}}},
+		{"cyclic", func(g *G, s *ProductElem) {
+			a := NewNonTerminal("a")
+			g.AddProduct(&Product{s, []*ProductElem{a}, nil})
+			g.AddProduct(&Product{a, []*ProductElem{s}, nil})
+			g.AddProduct(&Product{a, []*ProductElem{NewTerminal("x")}, nil})
+		}, []want{{CYCLIC_DERIVATION, SEVERITY_ERROR, "s"}}},
+		{"cyclic through nullable", func(g *G, s *ProductElem) {
+			opt := g.Optional(NewTerminal("x"))
+			g.AddProduct(&Product{s, []*ProductElem{opt, s}, nil})
+			g.AddProduct(&Product{s, []*ProductElem{NewTerminal("y")}, nil})
+		}, []want{{CYCLIC_DERIVATION, SEVERITY_ERROR, "s"}}},
+		{"clash", func(g *G, s *ProductElem) {
+			x := NewNonTerminal("x")
+			g.AddProduct(&Product{s, []*ProductElem{NewTerminal("x"), x}, nil})
+			g.AddProduct(&Product{x, []*ProductElem{NewTerminal("y")}, nil})
+		}, []want{{SYMBOL_CLASH, SEVERITY_ERROR, "x"}}},
+		{"type mismatch", func(g *G, s *ProductElem) {
+			g.AddProduct(&Product{s, []*ProductElem{NewTerminal("x")}, nil})
+			g.declareType(s, reflect.TypeOf(0))
+			g.declareType(s, reflect.TypeOf(""))
+		}, []want{{TYPE_MISMATCH, SEVERITY_ERROR, "s"}, {TYPE_MISMATCH, SEVERITY_WARNING, "s"}}},
+		{"unreachable", func(g *G, s *ProductElem) {
+			x := NewTerminal("x")
+			g.AddProduct(&Product{s, []*ProductElem{x}, nil})
+			g.AddProduct(&Product{NewNonTerminal("t"), []*ProductElem{x}, nil})
+		}, []want{{UNREACHABLE_SYMBOL, SEVERITY_WARNING, "t"}}},
+		{"duplicate", func(g *G, s *ProductElem) {
+			x := NewTerminal("x")
+			g.AddProduct(&Product{s, []*ProductElem{x}, nil})
+			g.AddProduct(&Product{s, []*ProductElem{x}, nil})
+		}, []want{{DUPLICATE_PRODUCT, SEVERITY_WARNING, "s"}}},
+	}
+	for _, tc := range tests {
+		s := NewNonTerminal("s")
+		g := NewGrammar(s)
+		tc.build(g, s)
+		diagnostics := g.Validate()
+		got := []want{}
+		for _, d := range diagnostics {
+			got = append(got, want{d.Kind, d.Severity, d.Symbol.Sig})
+		}
+		if len(got) != len(tc.want) || len(got) > 0 && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: diagnostics %v, want %v", tc.name, diagnostics, tc.want)
+		}
+
+		_, err := Compile(g)
+		ve, ok := err.(*ValidationError)
+		hasErrors := len(tc.want) > 0 && tc.want[0].severity == SEVERITY_ERROR
+		if ok != hasErrors || ok && len(ve.Diagnostics) == 0 {
+			t.Errorf("%v: Compile = %v", tc.name, err)
+		}
+	}
+}